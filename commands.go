@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/stephendotcarter/mysql-timeline/timeline"
+)
+
+// commands do something other than render a timeline, when named by the
+// first argument, e.g. "mysql-timeline matchers list"
+var commands = map[string]func(args []string) error{
+	"matchers": matchersCommand,
+	"states":   statesCommand,
+	"summary":  summaryCommand,
+
+	"recommend-bootstrap": recommendBootstrapCommand,
+}
+
+// matchersCommand lists the types of event that can be found, for --include
+// and --exclude
+func matchersCommand(args []string) error {
+	flags := flag.NewFlagSet("matchers", flag.ExitOnError)
+	rules := flags.String("rules", "", "YAML or JSON file of extra events to look for")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s matchers list [--rules FILE]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+
+	if len(args) == 0 || args[0] != "list" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	flags.Parse(args[1:])
+
+	parser, err := newParser(&options{Rules: *rules})
+	if err != nil {
+		return err
+	}
+	builtin := len(timeline.NewParser().Matchers())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tFROM\tSIGNATURE")
+	for i, eventMatcher := range parser.Matchers() {
+		from := "built in"
+		if i >= builtin {
+			from = *rules
+		}
+		signature := "-"
+		if eventMatcher.Signature != "" {
+			signature = fmt.Sprintf("%q", eventMatcher.Signature)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", eventMatcher.Description, from, signature)
+	}
+	return w.Flush()
+}
+
+// statesCommand prints the states each node was in and for how long, and the
+// events where a node's state went wrong
+func statesCommand(args []string) error {
+	_, nodes, events, nodeStreams, states := openTimeline(args)
+
+	// Only the states are needed, not the events
+	for events.Next() {
+	}
+	if err := events.Err(); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for i, node := range nodes {
+		fmt.Fprintf(w, "%s\n", node.Label())
+		if len(states.Spans[i]) == 0 {
+			fmt.Fprintf(w, "  no state changes found\n\n")
+			continue
+		}
+
+		var total time.Duration
+		for _, span := range states.Spans[i] {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", span.Start.Format(timeFormat), span.State, span.Duration().Round(time.Second))
+			total += span.Duration()
+		}
+
+		durations := states.Durations(i)
+		var names []string
+		for state := range durations {
+			names = append(names, state)
+		}
+		sort.Strings(names)
+		sort.SliceStable(names, func(a, b int) bool {
+			return durations[names[a]] > durations[names[b]]
+		})
+
+		fmt.Fprintf(w, "\n  Time in each state\n")
+		for _, state := range names {
+			share := 0.0
+			if total > 0 {
+				share = 100 * float64(durations[state]) / float64(total)
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%.1f%%\n", state, durations[state].Round(time.Second), share)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+
+	if len(states.Problems) > 0 {
+		formatter := timeline.NewPlainFormatter(nodes)
+		fmt.Fprintf(w, "Unexpected state changes\n")
+		for _, event := range states.Problems {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", event.Datetime.Format(timeFormat), nodes[event.Node].Label(), formatter.Message(event))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	printNodeWarnings(nodeStreams)
+	return nil
+}
+
+// summaryCommand prints a summary of the incident: when there was no primary
+// component, bootstraps, state transfers, crashes and the longest downtime
+func summaryCommand(args []string) error {
+	_, nodes, events, nodeStreams, states := openTimeline(args)
+
+	for events.Next() {
+	}
+	if err := events.Err(); err != nil {
+		return err
+	}
+
+	if err := timeline.Summarize(states, nodes).WriteText(os.Stdout, nodes); err != nil {
+		return err
+	}
+
+	printNodeWarnings(nodeStreams)
+	return nil
+}
+
+// recommendBootstrapCommand picks the node to bootstrap a cluster that is down
+// from, by the last position in each node's logs and any --grastate files
+func recommendBootstrapCommand(args []string) error {
+	// Positions are tracked before the events are filtered, so filters only
+	// change what is shown
+	positions := timeline.NewPositionTracker(0)
+	opts, nodes, events, nodeStreams, states := openTimeline(args, func(events timeline.Stream) timeline.Stream {
+		return timeline.TrackPositions(events, positions)
+	})
+
+	grastates, err := readGrastates(opts.Grastate)
+	if err != nil {
+		return err
+	}
+
+	for events.Next() {
+	}
+	if err := events.Err(); err != nil {
+		return err
+	}
+
+	recommendation := timeline.RecommendBootstrap(positions.Positions, grastates, nodes)
+	for i, node := range nodes {
+		if state := states.State(i); state != "" && state != timeline.StateDown {
+			recommendation.Warnings = append(recommendation.Warnings, fmt.Sprintf("%s: was %s when its logs end, make sure it is down", node.Label(), state))
+		}
+	}
+	if err := recommendation.WriteText(os.Stdout, nodes); err != nil {
+		return err
+	}
+
+	printNodeWarnings(nodeStreams)
+	return nil
+}
+
+// readGrastates reads the grastate.dat files given with --grastate, by node
+func readGrastates(paths map[int]string) (map[int]timeline.Position, error) {
+	grastates := make(map[int]timeline.Position)
+	for node, path := range paths {
+		position, err := timeline.ReadGrastate(path)
+		if err != nil {
+			return nil, err
+		}
+		grastates[node] = position
+	}
+	return grastates, nil
+}
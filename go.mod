@@ -0,0 +1,8 @@
+module github.com/stephendotcarter/mysql-timeline
+
+go 1.17
+
+require (
+	github.com/ulikunitz/xz v0.5.17
+	gopkg.in/yaml.v2 v2.4.0
+)
@@ -1,771 +1,419 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"regexp"
-	"sort"
+	"strconv"
 	"strings"
-	"text/template"
+	"sync"
 	"time"
+
+	"github.com/stephendotcarter/mysql-timeline/timeline"
 )
 
-// Event is an interesting event that occurred in MySQL logs
-//   - When it happened
-//   - Which node in the cluster
-//   - User friendly message
-//   - Raw log lines
-type Event struct {
-	Datetime      time.Time
-	GlobalOrderID int
-	Node          int
-	Message       string
-	Raw           string
-}
+// printWarnings summarises the events that could not be parsed in each file,
+// and any files that could not be read to the end
+func printWarnings(warnings []timeline.ParseWarning) {
+	counts := make(map[string]int)
+	for _, warning := range warnings {
+		if warning.Matcher != "" {
+			counts[warning.File]++
+		}
+	}
 
-// EventMatcher represents whats needed to find an event MySQL logs
-//   - Description of event
-//   - Function to match the event signature
-//   - Function to convert the raw text to an event
-type EventMatcher struct {
-	Description string
-	Signature   string
-	Get         func(*bufio.Scanner) *Event
+	file := ""
+	for _, warning := range warnings {
+		if warning.Matcher == "" {
+			os.Stderr.WriteString(fmt.Sprintf("Warning: %s\n", warning))
+			continue
+		}
+		if warning.File != file {
+			file = warning.File
+			os.Stderr.WriteString(fmt.Sprintf("Warning: %d unparsed events in %s\n", counts[file], file))
+		}
+		os.Stderr.WriteString(fmt.Sprintf("  %s\n", warning))
+	}
 }
 
-func NewEvent(eventTime time.Time, node int, message string, raw []string) *Event {
-	globalOrderID++
+// nodeFlags is the repeatable --node name=path flag
+type nodeFlags []timeline.Node
 
-	return &Event{
-		eventTime,
-		globalOrderID,
-		node,
-		message,
-		strings.Join(raw[:], "\n"),
+func (f *nodeFlags) String() string {
+	var names []string
+	for _, n := range *f {
+		names = append(names, fmt.Sprintf("%s=%s", n.Name, n.Path))
 	}
+	return strings.Join(names, ",")
+}
 
+func (f *nodeFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected name=path, got %q", value)
+	}
+	*f = append(*f, timeline.Node{Name: parts[0], Path: parts[1]})
+	return nil
 }
 
-func (e *EventMatcher) Match(line string) bool {
-	return strings.Contains(line, e.Signature)
+// listFlags is a repeatable flag, e.g. --include
+type listFlags []string
+
+func (f *listFlags) String() string {
+	return strings.Join(*f, ",")
 }
 
-func printDanger(line string) string {
-	return fmt.Sprintf("<danger>%s</danger>", line)
+func (f *listFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
 }
 
-func printSuccess(line string) string {
-	return fmt.Sprintf("<success>%s</success>", line)
+// options are the command line flags other than the nodes
+type options struct {
+	Format   string
+	Color    string
+	Width    int
+	CDN      bool
+	Context  int
+	Truncate int
+	Rules    string
+	Since    timeline.TimeSpec
+	Until    timeline.TimeSpec
+	Filter   timeline.EventFilter
+	Grastate map[int]string
 }
 
-var (
-	globalOrderID = 0 // Used to ensure timestamps within same second are ordered correctly
-
-	timeFormatDefault  = "2006-01-02 15:04:05"
-	timeFormatWsrepSst = "20060102 15:04:05"
-	timeFormatMysqld   = "060102 15:04:05"
-	timeFormatYMDHMS   = "20060102150405"
-
-	// Give each state a numeric value so shifts
-	// to a lower state can be flagged
-	shiftState = map[string]int{
-		"ERROR":          10,
-		"DESTROYED":      20,
-		"CLOSED":         30,
-		"OPEN":           40,
-		"PRIMARY":        50,
-		"JOINER":         60,
-		"DONOR/DESYNCED": 70,
-		"DONOR":          75,
-		"JOINED":         80,
-		"SYNCED":         90,
-	}
-
-	tmplTimeline = `{{define "Timeline"}}
-<style>
-body{ font-family: Courier New, Courier, monospace; }
-td { font-size: 10pt; white-space: pre-wrap; vertical-align: top; }
-.color-node0 { background: #D9B3FF; }
-.color-node1 { background: #B3B3FF; }
-.color-node2 { background: #B3D9FF; }
-success { color: #5cb85c; font-weight: bold; }
-danger { color: #d9534f; font-weight: bold; }
-</style>
-<table border="1">
-<thead>
-<th>Node</th><th>Date</th><th>Message</th>
-</thead>
-{{ range $event := .Timeline }}<tr class="color-{{ $event.Node }}"><td>{{ $event.Node }}</td><td>{{ $event.Datetime }}</td><td>{{ $event.Message }}</td></tr>
-{{ end }}
-</table>
-{{end}}`
-
-	// Event matchers for all know events
-	eventMatchers = []EventMatcher{
-		EventMatcher{
-			"Node is changing state",
-			"WSREP: Shifting",
-			func(scanner *bufio.Scanner) *Event {
-				// 2015-10-28 16:36:52 10144 [Note] WSREP: Shifting PRIMARY -> JOINER (TO: 31389)
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeDefault(lines[0])
-
-				matcher := regexp.MustCompile(` Shifting (.*) -> (.*) \(TO: ([0-9]*\))`)
-				matches := matcher.FindStringSubmatch(lines[0])
-
-				message := fmt.Sprintf("Shifting: %s to ", matches[1])
-
-				if shiftState[matches[1]] > shiftState[matches[2]] {
-					message = message + printDanger(matches[2])
-				} else {
-					message = message + printSuccess(matches[2])
-				}
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"Quorum results",
-			"WSREP: Quorum results:",
-			func(scanner *bufio.Scanner) *Event {
-				// 2015-10-28 14:28:50 553 [Note] WSREP: Quorum results:
-				//     version    = 3,
-				//     component  = PRIMARY,
-				//     conf_id    = 4,
-				//     members    = 3/3 (joined/total),
-				//     act_id     = 11152,
-				//     last_appl. = -1,
-				//     protocols  = 0/7/3 (gcs/repl/appl),
-				//     group UUID = 98ed75de-7c05-11e5-9743-de4abc22bd11
-				lines := scanLines(scanner, 9)
-				eventTime := getTimeDefault(lines[0])
-
-				matcher := regexp.MustCompile(`component  = (.*),`)
-				matches := matcher.FindStringSubmatch(lines[2])
-				component := matches[1]
-				matcher = regexp.MustCompile(`members    = ([0-9]*)/([0-9]*) \(joined/total\),`)
-				matches = matcher.FindStringSubmatch(lines[4])
-				membersJoined := matches[1]
-				membersTotal := matches[2]
-
-				componentString := component
-				if component == "PRIMARY" {
-					componentString = printSuccess(componentString)
-				} else {
-					componentString = printDanger(componentString)
-				}
-
-				membersString := fmt.Sprintf("%s/%s", membersJoined, membersTotal)
-				if membersJoined == membersTotal {
-					membersString = printSuccess(membersString)
-				} else {
-					membersString = printDanger(membersString)
-				}
-
-				message := fmt.Sprintf("Quorum results: Component = %s, Members = %s", componentString, membersString)
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"State Transfer Required",
-			"WSREP: State transfer required:",
-			func(scanner *bufio.Scanner) *Event {
-				// 2015-10-28 16:36:51 10144 [Note] WSREP: State transfer required:
-				//     Group state: 98ed75de-7c05-11e5-9743-de4abc22bd11:31382
-				//     Local state: 98ed75de-7c05-11e5-9743-de4abc22bd11:11152
-				lines := scanLines(scanner, 3)
-				eventTime := getTimeDefault(lines[0])
-
-				groupState := strings.SplitN(lines[1], ":", 3)
-				localState := strings.SplitN(lines[2], ":", 3)
-
-				groupStateString := fmt.Sprintf("%s:%s", strings.Trim(groupState[1], " "), strings.Trim(groupState[2], " "))
-				localStateString := fmt.Sprintf("%s:%s", strings.Trim(localState[1], " "), strings.Trim(localState[2], " "))
-				if localState[2] == "-1" {
-					localStateString = printDanger(localStateString)
-				} else {
-					localStateString = printSuccess(localStateString)
-				}
-
-				message := fmt.Sprintf("State transfer required:\n\tGroup: %s\n\tLocal: %s", groupStateString, localStateString)
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"WSREP recovered position",
-			"WSREP: Recovered position ",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-06-14 14:02:28 139993574066048 [Note] WSREP: Recovered position f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1:40847697
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeMysqld(lines[0])
-
-				matcher := regexp.MustCompile(`Recovered position (.*):(.*)`)
-				matches := matcher.FindStringSubmatch(lines[0])
-				uuid := matches[1]
-				seqno := matches[2]
-
-				recoveredString := fmt.Sprintf("%s:%s", uuid, seqno)
-				if seqno == "-1" {
-					recoveredString = printDanger(recoveredString)
-				} else {
-					recoveredString = printSuccess(recoveredString)
-				}
-
-				message := fmt.Sprintf("Recovered position: %s", recoveredString)
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"Interruptor",
-			"SST disabled due to danger of data loss",
-			func(scanner *bufio.Scanner) *Event {
-				// WSREP_SST: [ERROR] ############################################################################## (20170506 15:14:06.901)
-				// WSREP_SST: [ERROR] SST disabled due to danger of data loss. Verify data and bootstrap the cluster (20170506 15:14:06.902)
-				// WSREP_SST: [ERROR] ############################################################################## (20170506 15:14:06.904)
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeWsrepSst(lines[0])
-
-				message := printDanger(`++++++++++ INTERRUPTOR ++++++++++`)
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"MySQL ended",
-			" from pid file ",
-			func(scanner *bufio.Scanner) *Event {
-				// 170505 14:35:47 mysqld_safe mysqld from pid file /tmp/tmp-mysql.pid ended
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeMysqld(lines[0])
-
-				message := printDanger("PID ended")
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"MySQL normal shutdown",
-			"mysqld: Normal shutdown",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-05-05 14:35:45 139716968405760 [Note] /var/vcap/packages/mariadb/bin/mysqld: Normal shutdown
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeDefault(lines[0])
-
-				message := printSuccess("Normal Shutdown")
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"MySQL startup",
-			"starting as process",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-05-06 16:53:13 140445682804608 [Note] /var/vcap/packages/mariadb/bin/mysqld (mysqld 10.1.18-MariaDB) starting as process 24588 ...
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeDefault(lines[0])
-
-				message := "MySQL starting up"
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"InnoDB shutdown",
-			"InnoDB: Starting shutdown...",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-05-06 16:53:08 140348661906176 [Note] InnoDB: Starting shutdown...
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeDefault(lines[0])
-
-				message := "InnoDB shutting down"
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"InnoDB shutdown complete",
-			"mysqld: Shutdown complete",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-05-05 14:35:47 139716968405760 [Note] /var/vcap/packages/mariadb/bin/mysqld: Shutdown complete
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeDefault(lines[0])
-
-				message := "MySQL shutdown complete"
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"Primary not possible",
-			"WSREP: no nodes coming from prim view",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-05-05  6:50:37 140137601001344 [Warning] WSREP: no nodes coming from prim view, prim not possible
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeDefault(lines[0])
-
-				message := "Primary not possible"
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"Cluster View",
-			"WSREP: view(",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-06-14 10:11:35 139887269365504 [Note] WSREP: view(view_id(NON_PRIM,55433460,408) memb {
-				lines := scanLines(scanner, 1)
-
-				eventTime := getTimeDefault(lines[0])
-
-				view := ""
-				if strings.Contains(lines[0], "empty") {
-					view = "empty"
-				} else if strings.Contains(lines[0], "view_id") {
-					matcher := regexp.MustCompile(`view\(view_id\(([A-Z_]*),`)
-					matches := matcher.FindStringSubmatch(lines[0])
-					view = matches[1]
-				}
-
-				if view == "NON_PRIM" {
-					view = printDanger(view)
-				} else if view == "PRIM" {
-					view = printSuccess(view)
-				}
-
-				message := fmt.Sprintf("Cluster view: %s", view)
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"xtrabackup",
-			"WSREP: Running: ",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-06-14 19:10:58 140682204215040 [Note] WSREP: Running: 'wsrep_sst_xtrabackup-v2 --role 'joiner' --address '10.19.148.90' --datadir '/var/vcap/store/mysql/'   --parent '32691' --binlog 'mysql-bin' '
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeDefault(lines[0])
-
-				matcher := regexp.MustCompile(`--role '(.*)' --address '(.*?)' --`)
-				matches := matcher.FindStringSubmatch(lines[0])
-				role := matches[1]
-				address := matches[2]
-
-				message := ""
-				if role == "joiner" {
-					message = fmt.Sprintf("Node %s joining via SST", address)
-				} else if role == "donor" {
-					message = fmt.Sprintf("Node %s donating via SST", address)
-				} else {
-					message = "Oops :-o"
-				}
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"WSREP Transaction ID",
-			"WSREP: Set WSREPXid for InnoDB: ",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-06-22 16:50:12 140484737350400 [Note] WSREP: Set WSREPXid for InnoDB:  13f831b9-2d93-11e6-9385-a607db88d15b:36559417
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeDefault(lines[0])
-
-				matcher := regexp.MustCompile(`Set WSREPXid for InnoDB:  (.*)`)
-				matches := matcher.FindStringSubmatch(lines[0])
-				xid := matches[1]
-
-				message := fmt.Sprintf("WSREPXid = %s", xid)
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"Node consistency compromized",
-			"WSREP: Node consistency compromized",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-06-14  8:01:24 140433225386752 [ERROR] WSREP: Node consistency compromized, aborting...
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeDefault(lines[0])
-
-				message := printDanger("Node consistency compromized")
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"Slave SQL Error",
-			" Slave SQL: Error",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-03-24 10:25:00 140656657582848 [ERROR] Slave SQL: Error 'Table 'cf_f08ec188_bbf7_4a27_a001_97749f736849.COL1' doesn't exist' on query. Default database: 'cf_f08ec188_bbf7_4a27_a001_97749f736849'. Query: 'alter table COL1 drop foreign key FK8kw677hwx7cgwi4g1r6c56398', Internal MariaDB error code: 1146
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeDefault(lines[0])
-
-				//matcher := regexp.MustCompile(`Slave SQL: (Error.*), Internal MariaDB error code: (.*)`)
-				//matches := matcher.FindStringSubmatch(lines[0])
-				//error := matches[1]
-				//code := matches[2]
-
-				//message := fmt.Sprintf("%s\n%s", error, code)
-				message := printDanger("Slave SQL Error")
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"Fatal Error",
-			" Fatal error:",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-05-06 14:51:43 139983057127296 [ERROR] Fatal error: Can't open and lock privilege tables: Table 'mysql.user' doesn't exist
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeDefault(lines[0])
-
-				matcher := regexp.MustCompile(` Fatal error: (.*)`)
-				matches := matcher.FindStringSubmatch(lines[0])
-				fatalError := matches[1]
-
-				message := fmt.Sprintf(printDanger("Fatal Error: %s"), fatalError)
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"Assertion Failure",
-			"InnoDB: Assertion failure",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-06-22 15:51:49 7f99b39b7700  InnoDB: Assertion failure in thread 140298120034048 in file pars
-				lines := scanLines(scanner, 2)
-				eventTime := getTimeDefault(lines[0])
-
-				matcher := regexp.MustCompile(` InnoDB: (.*)`)
-				matches := matcher.FindStringSubmatch(lines[0])
-				assertion := printDanger(matches[1])
-
-				message := fmt.Sprintf("InnoDB: %s", assertion)
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"Bootstrap",
-			"WSREP: 'wsrep-new-cluster' option used",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-06-14 14:21:49 140348199405440 [Note] WSREP: 'wsrep-new-cluster' option used, bootstrapping the cluster
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeDefault(lines[0])
-
-				message := fmt.Sprintf(printDanger("++++++++++ BOOTSTRAPPING ++++++++++"))
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"Failed IST",
-			"WSREP: Failed to prepare for incremental state transfer",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-05-06 15:15:24 140137773021952 [Warning] WSREP: Failed to prepare for incremental state transfer: Local state UUID (00000000-0000-0000-0000-000000000000) does not match group state UUID (f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1): 1 (Operation not permitted)
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeDefault(lines[0])
-
-				message := fmt.Sprintf(printDanger("Failed to prepare for IST"))
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
-		EventMatcher{
-			"IST Received",
-			"WSREP: IST received:",
-			func(scanner *bufio.Scanner) *Event {
-				// 2017-05-06 15:15:24 140137773021952 [Warning] WSREP: Failed to prepare for incremental state transfer: Local state UUID (00000000-0000-0000-0000-000000000000) does not match group state UUID (f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1): 1 (Operation not permitted)
-				lines := scanLines(scanner, 1)
-				eventTime := getTimeDefault(lines[0])
-
-				message := fmt.Sprintf(printSuccess("IST Received"))
-
-				return NewEvent(eventTime, 0, message, lines)
-			},
-		},
+// parseArgs reads the flags and finds the nodes in the arguments, those after
+// the command if there is one
+func parseArgs(args []string) (*options, []timeline.Node, error) {
+	var named nodeFlags
+	var since, until, minSeverity, selected string
+	var include, exclude, grastates listFlags
+	opts := &options{}
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [--node name=path]... [--format html|text|json|ndjson|svg] [path]...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s states [flags] [path]...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s summary [flags] [path]...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s recommend-bootstrap [--grastate index=path]... [flags] [path]...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s matchers list [--rules FILE]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Var(&named, "node", "name a node, path is anything that can be given as an argument (repeatable)")
+	flag.StringVar(&opts.Format, "format", "html", "output format: html, text, json, ndjson or svg (a chart of the node states)")
+	flag.StringVar(&opts.Color, "color", "auto", "colour text output: auto (when writing to a terminal), always or never")
+	flag.IntVar(&opts.Width, "width", 0, "width of text output (default terminal width)")
+	flag.IntVar(&opts.Context, "context", 0, "number of log lines to keep before and after each event")
+	flag.IntVar(&opts.Truncate, "truncate", 0, "truncate the log lines and fields kept with each event to this many bytes (default keep them whole)")
+	flag.StringVar(&opts.Rules, "rules", "", "YAML or JSON file of extra events to look for")
+	flag.StringVar(&since, "since", "", "only show events from this time, e.g. \"2017-06-14 19:10\", \"2h before last BOOTSTRAPPING\" or \"around first NON_PRIM ±30m\"")
+	flag.StringVar(&until, "until", "", "only show events up to this time, e.g. \"2017-06-14 20:00\" or \"30m after first NON_PRIM\"")
+	flag.Var(&include, "include", "only show events of this type, see \"matchers list\" (repeatable)")
+	flag.Var(&exclude, "exclude", "don't show events of this type, e.g. \"WSREP Transaction ID\" (repeatable)")
+	flag.StringVar(&minSeverity, "min-severity", "info", "only show events at least this severe: info, success, warning or danger")
+	flag.StringVar(&selected, "nodes", "", "only show these nodes, by the indexes printed as they are found, e.g. 0,2")
+	flag.Var(&grastates, "grastate", "grastate.dat of a node for recommend-bootstrap, as index=path with the index as for --nodes (repeatable)")
+	flag.BoolVar(&opts.CDN, "cdn", false, "load Bootstrap from its CDN instead of embedding the styles in the HTML")
+	flag.CommandLine.Parse(args)
+
+	switch opts.Format {
+	case "html", "text", "json", "ndjson", "svg":
+	default:
+		return nil, nil, fmt.Errorf("unknown --format %q", opts.Format)
 	}
-)
 
-func getTimeDefault(line string) time.Time {
-	// "2006-01-02 15:04:05"
-	t, err := time.Parse(timeFormatDefault, line[:19])
+	if opts.Context < 0 {
+		return nil, nil, fmt.Errorf("--context must not be negative")
+	}
 
-	if err != nil {
-		fmt.Println("Oops")
+	if opts.Truncate < 0 {
+		return nil, nil, fmt.Errorf("--truncate must not be negative")
 	}
 
-	return t
-}
+	switch opts.Color {
+	case "auto", "always", "never":
+	default:
+		return nil, nil, fmt.Errorf("unknown --color %q", opts.Color)
+	}
 
-func getTimeWsrepSst(line string) time.Time {
-	// "20060102 15:04:05"
-	matcher := regexp.MustCompile(`([0-9]{8} [0-9]{2}:[0-9]{2}:[0-9]{2})`)
-	matches := matcher.FindStringSubmatch(line)
-	t, err := time.Parse(timeFormatWsrepSst, matches[1])
+	if since != "" {
+		spec, err := timeline.ParseTimeSpec(since)
+		if err != nil {
+			return nil, nil, fmt.Errorf("--since: %s", err)
+		}
+		opts.Since = spec
+	}
+	if until != "" {
+		spec, err := timeline.ParseTimeSpec(until)
+		if err != nil {
+			return nil, nil, fmt.Errorf("--until: %s", err)
+		}
+		opts.Until = spec
+	}
 
+	severity, err := timeline.ParseSeverity(minSeverity)
 	if err != nil {
-		fmt.Println("Oops")
+		return nil, nil, fmt.Errorf("--min-severity: %s", err)
 	}
+	opts.Filter = timeline.EventFilter{Include: include, Exclude: exclude, MinSeverity: severity}
 
-	return t
-}
-
-func getTimeMysqld(line string) time.Time {
-	// "060102 15:04:05"
-	matcher := regexp.MustCompile(`([0-9]{6} [0-9]{2}:[0-9]{2}:[0-9]{2})`)
-	matches := matcher.FindStringSubmatch(line)
-	t, err := time.Parse(timeFormatMysqld, matches[1])
+	var nodes []timeline.Node
+	for _, n := range named {
+		found, err := timeline.DiscoverNodes([]string{n.Path})
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(found) != 1 {
+			return nil, nil, fmt.Errorf("--node %s=%s: found %d nodes, expected 1", n.Name, n.Path, len(found))
+		}
+		found[0].Name = n.Name
+		nodes = append(nodes, found[0])
+	}
 
+	found, err := timeline.DiscoverNodes(flag.Args())
 	if err != nil {
-		fmt.Println("Oops")
+		return nil, nil, err
 	}
 
-	return t
-}
-
-func scanLines(scanner *bufio.Scanner, count int) []string {
-	var lines []string
-	for {
-		lines = append(lines, scanner.Text())
-		count--
-		if count == 0 {
-			return lines
-		}
-		scanner.Scan()
+	nodes = append(nodes, found...)
+	if len(nodes) == 0 {
+		flag.Usage()
+		return nil, nil, fmt.Errorf("no logs given")
 	}
-}
 
-func getEventsFromNode(node int, filePath string) []*Event {
-	var events []*Event
+	// Nodes named with --node come first, and a bundle can hold several, so
+	// the indexes --nodes and --grastate use are printed
+	for i, node := range nodes {
+		fmt.Fprintf(os.Stderr, "Node %d: %s\n", i, node.Label())
+	}
 
-	file, err := os.Open(filePath)
+	// --grastate gives the indexes of all the nodes, like --nodes
+	opts.Grastate, err = parseGrastates(grastates, len(nodes))
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, fmt.Errorf("--grastate: %s", err)
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		for _, eventMatcher := range eventMatchers {
-			if eventMatcher.Match(scanner.Text()) {
-				event := eventMatcher.Get(scanner)
-				event.Node = node
-				events = append(events, event)
-				break
-			}
+	if selected != "" {
+		indexes, err := selectNodes(len(nodes), selected)
+		if err != nil {
+			return nil, nil, fmt.Errorf("--nodes: %s", err)
+		}
+		nodes, opts.Grastate, err = keepNodes(nodes, opts.Grastate, indexes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("--grastate: %s", err)
 		}
 	}
 
-	return events
+	return opts, nodes, nil
 }
 
-func filterFormatAnchor(anchor string) string {
-	anchor = strings.Replace(anchor, "-", "", -1)
-	anchor = strings.Replace(anchor, ":", "", -1)
-	anchor = strings.Replace(anchor, " ", "_", -1)
-	return anchor
+// selectNodes reads a comma separated list of the indexes of nodes
+func selectNodes(nodes int, list string) ([]int, error) {
+	var selected []int
+	seen := make(map[int]bool)
+	for _, field := range strings.Split(list, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || i < 0 || i >= nodes {
+			return nil, fmt.Errorf("%q is not a node, expected 0 to %d", field, nodes-1)
+		}
+		if seen[i] {
+			return nil, fmt.Errorf("node %d is given twice", i)
+		}
+		seen[i] = true
+		selected = append(selected, i)
+	}
+	return selected, nil
 }
 
-func renderHTML(timeline []*Event) string {
-	html := ""
-	t, err := template.New("foo").Parse(tmplTimeline)
-	if err != nil {
-		panic(err)
+// parseGrastates reads the grastate.dat files given as index=path, by node
+func parseGrastates(flags []string, nodes int) (map[int]string, error) {
+	grastates := make(map[int]string)
+	for _, value := range flags {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected index=path, got %q", value)
+		}
+		node, err := strconv.Atoi(parts[0])
+		if err != nil || node < 0 || node >= nodes {
+			return nil, fmt.Errorf("%q is not a node, expected 0 to %d", parts[0], nodes-1)
+		}
+		grastates[node] = parts[1]
 	}
+	return grastates, nil
+}
 
-	type renderData struct {
-		Timeline []*Event
+// keepNodes keeps the nodes with the indexes, in that order, and moves their
+// grastate.dat files to their new indexes
+func keepNodes(nodes []timeline.Node, grastates map[int]string, indexes []int) ([]timeline.Node, map[int]string, error) {
+	var kept []timeline.Node
+	moved := make(map[int]string)
+	for i, index := range indexes {
+		kept = append(kept, nodes[index])
+		if path, ok := grastates[index]; ok {
+			moved[i] = path
+			delete(grastates, index)
+		}
 	}
-
-	data := renderData{
-		timeline,
+	for index := range grastates {
+		return nil, nil, fmt.Errorf("node %d is not one of --nodes", index)
 	}
-
-	var doc bytes.Buffer
-	t.ExecuteTemplate(&doc, "Timeline", data)
-	html = doc.String()
-	return html
+	return kept, moved, nil
 }
 
-func renderHTMLCols(timeline []*Event, files []string) string {
-
-	var timelineCols = make(map[string][][]*Event)
-
-	var tmplTimelineCols = `{{define "Timeline"}}
-<html>
-<head>
-<link rel="stylesheet" href="https://maxcdn.bootstrapcdn.com/bootstrap/4.0.0/css/bootstrap.min.css" integrity="sha384-Gn5384xqQ1aoWXA+058RXPxPg6fy4IWvTNh0E263XmFcJlSAwiGgFAW/dAiS6JXm" crossorigin="anonymous">
-<style>
-body{ font-family: Courier New, Courier, monospace; }
-th { font-size: 10pt; vertical-align: top; }
-td { font-size: 10pt; white-space: pre-wrap; vertical-align: top; }
-.nowrap { white-space: nowrap; }
-success { color: #5cb85c; font-weight: bold; }
-danger { color: #d9534f; font-weight: bold; }
-</style>
-
-<script src="https://code.jquery.com/jquery-3.2.1.slim.min.js" integrity="sha384-KJ3o2DKtIkvYIK3UENzmM7KCkRr/rE9/Qpg6aAZGJwFDMVNA/GpGFF93hXpG5KkN" crossorigin="anonymous"></script>
-<script src="https://cdnjs.cloudflare.com/ajax/libs/popper.js/1.12.9/umd/popper.min.js" integrity="sha384-ApNbgh9B+Y1QKtv3Rn7W3mgPxhU9K/ScQsAP7hUibX39j7fakFPskvXusvfa0b4Q" crossorigin="anonymous"></script>
-<script src="https://maxcdn.bootstrapcdn.com/bootstrap/4.0.0/js/bootstrap.min.js" integrity="sha384-JZR6Spejh4U02d8jOt6vLEHfe/JQGiRRSQQxSfFWpi1MquVdAyjUar5+76PVCmYl" crossorigin="anonymous"></script>
-<script>
-
-var lastSelectedRow;
-var trs ;
-
-function triggers() {
-$('tr').click(function(event){
-        if (event.shiftKey) {
-            selectRowsBetweenIndexes([lastSelectedRow.rowIndex, this.rowIndex])
-        } else {
-                clearAll();
-                toggleRow(this)
-        }
-        //$(this).addClass("out");
-        //$(this).removeClass("in");
-});
-}
+// newParser builds a parser for the built in events and those in the --rules file
+func newParser(opts *options) (*timeline.Parser, error) {
+	parser := timeline.NewParser()
+	parser.Context = opts.Context
+	parser.MaxLineLength = opts.Truncate
+	parser.Progress = os.Stderr
 
-function toggleRow(row) {
-    if ( !$(row).hasClass("table-active")) {
-        $(row).addClass("table-active");
-    }
-    lastSelectedRow = row;
-}
+	if opts.Rules == "" {
+		return parser, nil
+	}
 
-function selectRowsBetweenIndexes(indexes) {
-    indexes.sort(function(a, b) {
-        return a - b;
-    });
+	rules, err := timeline.LoadRules(opts.Rules)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if err := parser.RegisterMatcher(rule); err != nil {
+			return nil, fmt.Errorf("%s: %s", opts.Rules, err)
+		}
+	}
 
-    for (var i = indexes[0]; i <= indexes[1]; i++) {
-        $(trs[i]).addClass('table-active');
-    }
+	return parser, nil
 }
 
-function hideSelected() {
-        for (var i = 0; i < trs.length; i++) {
-                if ( $(trs[i]).hasClass("table-active") ) {
-                        $(trs[i]).removeClass("show");
-                }
-        }
-        clearAll();
+// Events parsed ahead of the merge for each node
+const streamBuffer = 1000
+
+// streamNodes parses each node in its own goroutine, merging the events as
+// they are read
+func streamNodes(parser *timeline.Parser, nodes []timeline.Node) (timeline.Stream, []*timeline.NodeStream) {
+	var nodeStreams []*timeline.NodeStream
+	var streams []timeline.Stream
+	for i := range nodes {
+		stream := parser.StreamNode(i, nodes[i])
+		nodeStreams = append(nodeStreams, stream)
+		streams = append(streams, timeline.Background(stream, streamBuffer))
+	}
+	return timeline.MergeStreams(streams...), nodeStreams
 }
 
-function clearAll() {
-        for (var i = 0; i < trs.length; i++) {
-                $(trs[i]).removeClass("table-active");
-        }
+// identifyNodes finds the name, address and UUIDs of each node, in parallel,
+// so they can be shown before the events are streamed
+func identifyNodes(nodes []timeline.Node) {
+	var wg sync.WaitGroup
+	for i := range nodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			nodes[i].Identify()
+		}(i)
+	}
+	wg.Wait()
 }
 
-function expandAll() {
-        for (var i = 0; i < trs.length; i++) {
-                if ( !$(trs[i]).hasClass("show") ) {
-                        $(trs[i]).addClass('show');
-                }
-        }
-}
+// Format of the times written by the commands, like those in the logs
+const timeFormat = "2006-01-02 15:04:05"
 
-function populateTRS() {
-        trs = document.getElementsByTagName('tr');
+// windowEnd describes one end of the window, or what it is if it is open
+func windowEnd(t time.Time, open string) string {
+	if t.IsZero() {
+		return open
+	}
+	return t.Format(timeFormat)
 }
-</script>
-
-</head>
-<body onload="triggers(); populateTRS(); expandAll();">
-<button type="button" class="btn btn-info"  onclick="hideSelected();">Hide Selected</button>
-<button type="button" class="btn btn-info"  onclick="expandAll();">Expand</button>
-<table class="table table-bordered table-condensed">
-<thead>
-<th class="align-top">Timestamp</th>
-{{ range $file := .Files }}
-<th class="align-top">{{ $file }}</th>
-{{ end }}
-</thead>
-<tbody>
-{{ range $time, $nodes := .Timeline }}
-<tr class="collapse">
-<td class="nowrap"><a name="{{ $time | FormatAnchor }}" href="#{{ $time | FormatAnchor }}">{{ $time }}</td>
-{{ range $node := $nodes }}
-<td>{{ range $event := $node }}{{ $event.Message }}<br>{{ end }}</td>
-{{ end }}
-</tr>
-{{ end }}
-</tbody>
-</table>
-</body>
-</html>
-{{end}}`
-
-	for _, event := range timeline {
-		timeString := event.Datetime.Format("2006-01-02 15:04:05")
-		if _, ok := timelineCols[timeString]; !ok {
-			timelineCols[timeString] = make([][]*Event, len(files))
-		}
 
-		timelineCols[timeString][event.Node] = append(timelineCols[timeString][event.Node], event)
+// openTimeline does everything before rendering: it reads the arguments,
+// identifies the nodes and starts streaming the events in the window. The
+// states of the nodes, and anything else track adds, are tracked before the
+// events are filtered, so the events that change them are always seen.
+func openTimeline(args []string, track ...func(timeline.Stream) timeline.Stream) (*options, []timeline.Node, timeline.Stream, []*timeline.NodeStream, *timeline.StateTracker) {
+	opts, nodes, err := parseArgs(args)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	filters := template.FuncMap{
-		"FormatAnchor": filterFormatAnchor,
+	parser, err := newParser(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := opts.Filter.Check(parser); err != nil {
+		log.Fatalf("%s, see %s matchers list", err, os.Args[0])
 	}
 
-	t, err := template.New("foo").Funcs(filters).Parse(tmplTimelineCols)
+	os.Stderr.WriteString("Identifying nodes\n")
+	identifyNodes(nodes)
+
+	// A window relative to an event needs the logs read once to find it
+	var events timeline.Stream
+	if opts.Since.Relative() || opts.Until.Relative() {
+		os.Stderr.WriteString("Finding the events --since and --until refer to\n")
+		events, _ = streamNodes(parser, nodes)
+	}
+	parser.Window, err = timeline.ResolveWindow(opts.Since, opts.Until, events, nodes)
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
-
-	type renderData struct {
-		Timeline map[string][][]*Event
-		Files    []string
+	if !opts.Since.IsZero() || !opts.Until.IsZero() {
+		os.Stderr.WriteString(fmt.Sprintf("Showing events from %s to %s\n", windowEnd(parser.Window.Since, "the start"), windowEnd(parser.Window.Until, "the end")))
 	}
 
-	data := renderData{
-		timelineCols,
-		files,
+	// Each node is parsed in its own goroutine, and merged as it is read
+	events, nodeStreams := streamNodes(parser, nodes)
+	states := timeline.NewStateTracker(len(nodes))
+	events = timeline.TrackStates(events, states)
+	for _, t := range track {
+		events = t(events)
 	}
+	events = timeline.FilterStream(events, opts.Filter.Keep)
 
-	var doc bytes.Buffer
-	t.ExecuteTemplate(&doc, "Timeline", data)
-	html := doc.String()
-	return html
+	return opts, nodes, events, nodeStreams, states
 }
 
-func parseArgs() []string {
-	files := os.Args[1:]
-	return files
+// printNodeWarnings prints the warnings of all the nodes, once they have been read
+func printNodeWarnings(nodeStreams []*timeline.NodeStream) {
+	var warnings []timeline.ParseWarning
+	for _, stream := range nodeStreams {
+		warnings = append(warnings, stream.Warnings()...)
+	}
+	printWarnings(warnings)
 }
 
 func main() {
 
-	var files = parseArgs()
-
-	var timeline []*Event
-
-	for i, filePath := range files {
-		node := i
-		os.Stderr.WriteString(fmt.Sprintf("Parsing file %s\n", filePath))
-		timeline = append(timeline, getEventsFromNode(node, filePath)...)
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
 	}
 
-	os.Stderr.WriteString("Sorting\n")
-	sort.Slice(timeline, func(i, j int) bool {
-		if timeline[i].Datetime.Equal(timeline[j].Datetime) {
-			return timeline[i].GlobalOrderID < timeline[j].GlobalOrderID
-		}
-		return timeline[i].Datetime.Before(timeline[j].Datetime)
-	})
+	opts, nodes, events, nodeStreams, states := openTimeline(os.Args[1:])
+
+	var renderer timeline.Renderer
+	switch opts.Format {
+	case "text":
+		color := opts.Color == "always" || (opts.Color == "auto" && isTerminal(os.Stdout))
+		renderer = timeline.TextRenderer{Width: textWidth(opts.Width), Color: color}
+	case "json":
+		renderer = timeline.JSONRenderer{}
+	case "ndjson":
+		renderer = timeline.NDJSONRenderer{}
+	case "svg":
+		renderer = timeline.SVGRenderer{States: states}
+	default:
+		renderer = timeline.HTMLRenderer{CDN: opts.CDN, States: states}
+	}
 
 	os.Stderr.WriteString("Rendering\n")
-	html := renderHTMLCols(timeline, files)
+	if err := renderer.Render(os.Stdout, events, nodes); err != nil {
+		log.Fatal(err)
+	}
 
-	os.Stderr.WriteString("Printing\n")
-	fmt.Println(html)
+	printNodeWarnings(nodeStreams)
 }
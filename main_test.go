@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stephendotcarter/mysql-timeline/timeline"
+)
+
+func TestKeepNodes(t *testing.T) {
+	nodes := []timeline.Node{{Path: "node0"}, {Path: "node1"}, {Path: "node2"}}
+
+	tests := []struct {
+		name      string
+		grastates []string
+		indexes   []int
+		wantNodes []string
+		want      map[int]string
+		wantErr   bool
+	}{
+		{"moved with its node", []string{"2=grastate2.dat"}, []int{1, 2}, []string{"node1", "node2"}, map[int]string{1: "grastate2.dat"}, false},
+		{"in the order of --nodes", []string{"0=grastate0.dat", "2=grastate2.dat"}, []int{2, 0}, []string{"node2", "node0"}, map[int]string{0: "grastate2.dat", 1: "grastate0.dat"}, false},
+		{"none", nil, []int{1}, []string{"node1"}, map[int]string{}, false},
+		{"node not kept", []string{"0=grastate0.dat"}, []int{1, 2}, nil, nil, true},
+	}
+
+	for _, test := range tests {
+		grastates, err := parseGrastates(test.grastates, len(nodes))
+		if err != nil {
+			t.Fatal(err)
+		}
+		kept, got, err := keepNodes(nodes, grastates, test.indexes)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v, want error %t", test.name, err, test.wantErr)
+			continue
+		}
+		var paths []string
+		for _, node := range kept {
+			paths = append(paths, node.Path)
+		}
+		if !reflect.DeepEqual(paths, test.wantNodes) || (!test.wantErr && !reflect.DeepEqual(got, test.want)) {
+			t.Errorf("%s: got %v with %v, want %v with %v", test.name, paths, got, test.wantNodes, test.want)
+		}
+	}
+}
+
+func TestParseGrastates(t *testing.T) {
+	for _, flags := range [][]string{{"grastate.dat"}, {"3=grastate.dat"}, {"-1=grastate.dat"}, {"x=grastate.dat"}} {
+		if _, err := parseGrastates(flags, 3); err == nil {
+			t.Errorf("%q: expected an error", flags)
+		}
+	}
+}
+
+func TestSelectNodes(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []int
+		wantErr bool
+	}{
+		{"0,2", []int{0, 2}, false},
+		{"2, 0", []int{2, 0}, false},
+		{"1", []int{1}, false},
+		{"0,0", nil, true},
+		{"1, 1", nil, true},
+		{"3", nil, true},
+		{"-1", nil, true},
+		{"0,x", nil, true},
+		{"0,", nil, true},
+	}
+
+	for _, test := range tests {
+		got, err := selectNodes(3, test.list)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: got error %v, want error %t", test.list, err, test.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.list, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+// Used when the width can't be found from the terminal or $COLUMNS
+const defaultTextWidth = 160
+
+// textWidth is the width to render text in, the terminal width unless given
+func textWidth(width int) int {
+	if width > 0 {
+		return width
+	}
+	if width, ok := terminalWidth(os.Stdout); ok {
+		return width
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return defaultTextWidth
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
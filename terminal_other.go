@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package main
+
+import "os"
+
+// terminalWidth is not supported on this platform, $COLUMNS or the default is used
+func terminalWidth(f *os.File) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,23 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// terminalWidth asks the terminal how many columns it has
+func terminalWidth(f *os.File) (int, bool) {
+	var size struct {
+		Rows, Cols, XPixel, YPixel uint16
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size)))
+	if errno != 0 || size.Cols == 0 {
+		return 0, false
+	}
+
+	return int(size.Cols), true
+}
@@ -0,0 +1,56 @@
+var lastSelectedRow;
+var trs;
+
+function triggers() {
+    var rows = document.getElementsByTagName('tr');
+    for (var i = 0; i < rows.length; i++) {
+        rows[i].addEventListener('click', function(event) {
+            if (event.shiftKey && lastSelectedRow) {
+                selectRowsBetweenIndexes([lastSelectedRow.rowIndex, this.rowIndex]);
+            } else {
+                clearAll();
+                toggleRow(this);
+            }
+        });
+    }
+}
+
+function toggleRow(row) {
+    row.classList.add('table-active');
+    lastSelectedRow = row;
+}
+
+function selectRowsBetweenIndexes(indexes) {
+    indexes.sort(function(a, b) {
+        return a - b;
+    });
+
+    for (var i = indexes[0]; i <= indexes[1]; i++) {
+        trs[i].classList.add('table-active');
+    }
+}
+
+function hideSelected() {
+    for (var i = 0; i < trs.length; i++) {
+        if (trs[i].classList.contains('table-active')) {
+            trs[i].classList.remove('show');
+        }
+    }
+    clearAll();
+}
+
+function clearAll() {
+    for (var i = 0; i < trs.length; i++) {
+        trs[i].classList.remove('table-active');
+    }
+}
+
+function expandAll() {
+    for (var i = 0; i < trs.length; i++) {
+        trs[i].classList.add('show');
+    }
+}
+
+function populateTRS() {
+    trs = document.getElementsByTagName('tr');
+}
@@ -0,0 +1,320 @@
+package timeline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UUID of a node with no state, e.g. after its data was removed
+const zeroUUID = "00000000-0000-0000-0000-000000000000"
+
+// Position is how far through the cluster's history a node got
+//   - UUID of the history, the same on every node of a cluster
+//   - Seqno of the last transaction applied, -1 if it isn't known
+//   - When it was logged, zero for a grastate.dat
+//   - Where it was found, e.g. the log and line
+//   - Whether grastate.dat says it is safe to bootstrap from, for a grastate.dat
+//   - Whether a position was found at all
+type Position struct {
+	UUID            string
+	Seqno           int64
+	Time            time.Time
+	Source          string
+	SafeToBootstrap bool
+	Known           bool
+}
+
+func (p Position) String() string {
+	if !p.Known {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s:%d", p.UUID, p.Seqno)
+}
+
+// States a node only shifts from or to once it has the cluster's data, so the
+// seqno of the shift is how far it got
+var dataStates = map[string]bool{
+	"JOINED":         true,
+	"SYNCED":         true,
+	"DONOR/DESYNCED": true,
+	"DONOR":          true,
+}
+
+// PositionTracker keeps the last position each node's logs show, from its
+// recovered positions, the local state of state transfers, and the seqnos of
+// shifts once it has the data of the cluster's history
+//   - Last position of each node
+//   - History each node's cluster is on, from its quorum results
+type PositionTracker struct {
+	Positions []Position
+	histories map[int]string
+}
+
+// NewPositionTracker tracks the nodes of a timeline, by their index
+func NewPositionTracker(nodes int) *PositionTracker {
+	return &PositionTracker{make([]Position, nodes), make(map[int]string)}
+}
+
+// TrackPositions passes each event of the stream to the tracker as it is read
+func TrackPositions(stream Stream, tracker *PositionTracker) Stream {
+	return FilterStream(stream, func(e *Event) bool {
+		tracker.Track(e)
+		return true
+	})
+}
+
+// Track records the position in the event, if it has one. Events must be
+// tracked in the order they happened.
+func (t *PositionTracker) Track(e *Event) {
+	if _, ok := e.Fields["parse_error"]; ok {
+		return
+	}
+
+	var uuid, seqno interface{}
+	switch e.Type {
+	case "WSREP recovered position":
+		uuid, seqno = e.Fields["uuid"], e.Fields["seqno"]
+	case "State Transfer Required":
+		t.histories[e.Node] = fmt.Sprint(e.Fields["group_uuid"])
+		uuid, seqno = e.Fields["local_uuid"], e.Fields["local_seqno"]
+	case "Quorum results":
+		t.histories[e.Node] = fmt.Sprint(e.Fields["uuid"])
+		return
+	case "Node is changing state":
+		history, ok := t.histories[e.Node]
+		if !ok || !(dataStates[fmt.Sprint(e.Fields["from"])] || dataStates[fmt.Sprint(e.Fields["to"])]) {
+			return
+		}
+		uuid, seqno = history, e.Fields["seqno"]
+	default:
+		return
+	}
+
+	for e.Node >= len(t.Positions) {
+		t.Positions = append(t.Positions, Position{})
+	}
+	position := Position{
+		UUID:   fmt.Sprint(uuid),
+		Time:   e.Datetime,
+		Source: fmt.Sprintf("%s %s:%d", e.Type, e.Source, e.Line),
+		Known:  true,
+	}
+	position.Seqno, _ = seqno.(int64)
+	t.Positions[e.Node] = position
+}
+
+// ReadGrastate reads the position saved in a node's grastate.dat
+//
+//	# GALERA saved state
+//	version: 2.1
+//	uuid:    f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1
+//	seqno:   -1
+//	safe_to_bootstrap: 0
+func ReadGrastate(filePath string) (Position, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return Position{}, err
+	}
+	defer file.Close()
+
+	position := Position{Source: filePath, Seqno: -1}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch strings.TrimSpace(parts[0]) {
+		case "uuid":
+			position.UUID = value
+			position.Known = true
+		case "seqno":
+			if position.Seqno, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return Position{}, fmt.Errorf("%s: seqno %q is not a number", filePath, value)
+			}
+		case "safe_to_bootstrap":
+			position.SafeToBootstrap = value == "1"
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Position{}, err
+	}
+	if !position.Known {
+		return Position{}, fmt.Errorf("%s: no uuid, is it a grastate.dat?", filePath)
+	}
+	return position, nil
+}
+
+// Recommendation is the node to bootstrap a cluster that is down from
+//   - Index of the node, -1 if none can be recommended
+//   - Position of each node that it was chosen from
+//   - Why it was chosen
+//   - What to look out for, e.g. nodes that can't be compared
+type Recommendation struct {
+	Node      int
+	Positions []Position
+	Reasons   []string
+	Warnings  []string
+}
+
+// RecommendBootstrap picks the node with the most advanced state, from the
+// positions in the logs and any grastate.dat files, by node index. A
+// grastate.dat is used over the logs unless its seqno is -1, as it is after
+// a crash. Nodes with a seqno of -1, or a history (UUID) that the most nodes
+// don't share, are not recommended.
+func RecommendBootstrap(logged []Position, grastates map[int]Position, nodes []Node) *Recommendation {
+	label := func(node int) string {
+		if node < len(nodes) {
+			return nodes[node].Label()
+		}
+		return fmt.Sprintf("node %d", node)
+	}
+
+	count := len(nodes)
+	if len(logged) > count {
+		count = len(logged)
+	}
+	r := &Recommendation{Node: -1, Positions: make([]Position, count)}
+
+	for node := range r.Positions {
+		if node < len(logged) {
+			r.Positions[node] = logged[node]
+		}
+		if grastate, ok := grastates[node]; ok {
+			if grastate.Seqno != -1 || !r.Positions[node].Known {
+				r.Positions[node] = grastate
+			}
+		}
+	}
+
+	// Nodes that can be compared, grouped by the history they are on
+	histories := make(map[string][]int)
+	for node, position := range r.Positions {
+		switch {
+		case !position.Known:
+			r.Warnings = append(r.Warnings, fmt.Sprintf("%s: no position found, give its grastate.dat with --grastate or run mysqld --wsrep-recover", label(node)))
+		case position.UUID == zeroUUID:
+			r.Warnings = append(r.Warnings, fmt.Sprintf("%s: has no state (UUID %s), it will need an SST", label(node), zeroUUID))
+		case position.Seqno == -1:
+			r.Warnings = append(r.Warnings, fmt.Sprintf("%s: seqno is -1 (%s), it did not shut down cleanly. Run mysqld --wsrep-recover to find its position", label(node), position.Source))
+		default:
+			histories[position.UUID] = append(histories[position.UUID], node)
+		}
+	}
+	if len(histories) == 0 {
+		r.Reasons = append(r.Reasons, "no node has a known position to compare")
+		return r
+	}
+
+	// The history most nodes share is the cluster's, ties going to the history
+	// with the highest seqno
+	var uuids []string
+	for uuid := range histories {
+		uuids = append(uuids, uuid)
+	}
+	sort.Slice(uuids, func(i, j int) bool {
+		a, b := histories[uuids[i]], histories[uuids[j]]
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return r.highest(a) > r.highest(b)
+	})
+	uuid := uuids[0]
+	for _, other := range uuids[1:] {
+		for _, node := range histories[other] {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("%s: UUID %s does not match %s of the other nodes, its data is from a different history and it will need an SST", label(node), other, uuid))
+		}
+	}
+	if len(uuids) > 1 && len(histories[uuids[0]]) == len(histories[uuids[1]]) {
+		r.Warnings = append(r.Warnings, "as many nodes are on another history, check which one the applications wrote to before bootstrapping")
+	}
+
+	// The most advanced node, ties going to one grastate.dat says is safe
+	candidates := histories[uuid]
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := r.Positions[candidates[i]], r.Positions[candidates[j]]
+		if a.Seqno != b.Seqno {
+			return a.Seqno > b.Seqno
+		}
+		return a.SafeToBootstrap && !b.SafeToBootstrap
+	})
+	r.Node = candidates[0]
+	best := r.Positions[r.Node]
+
+	r.Reasons = append(r.Reasons, fmt.Sprintf("it has the highest seqno, %d, of the nodes on history %s (%s)", best.Seqno, uuid, best.Source))
+	for _, node := range candidates[1:] {
+		position := r.Positions[node]
+		if position.Seqno == best.Seqno {
+			r.Reasons = append(r.Reasons, fmt.Sprintf("%s is as advanced, at %d, and could be bootstrapped instead", label(node), position.Seqno))
+			continue
+		}
+		r.Reasons = append(r.Reasons, fmt.Sprintf("%s is behind, at %d (%d transactions)", label(node), position.Seqno, best.Seqno-position.Seqno))
+	}
+	if grastate, ok := grastates[r.Node]; ok && !grastate.SafeToBootstrap {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("%s: set safe_to_bootstrap: 1 in %s before bootstrapping it", label(r.Node), grastate.Source))
+	}
+
+	return r
+}
+
+// highest is the highest seqno of the nodes
+func (r *Recommendation) highest(nodes []int) int64 {
+	highest := int64(-1)
+	for _, node := range nodes {
+		if r.Positions[node].Seqno > highest {
+			highest = r.Positions[node].Seqno
+		}
+	}
+	return highest
+}
+
+// WriteText writes the recommendation and the positions it was made from
+func (r *Recommendation) WriteText(w io.Writer, nodes []Node) error {
+	label := func(node int) string {
+		if node < len(nodes) {
+			return nodes[node].Label()
+		}
+		return fmt.Sprintf("node %d", node)
+	}
+
+	fmt.Fprintln(w, "Positions")
+	for node, position := range r.Positions {
+		from := position.Source
+		if !position.Time.IsZero() {
+			from = fmt.Sprintf("%s at %s", from, position.Time.Format(timeFormatDefault))
+		}
+		if position.Known {
+			fmt.Fprintf(w, "  %s: %s, from %s\n", label(node), position, from)
+		} else {
+			fmt.Fprintf(w, "  %s: unknown\n", label(node))
+		}
+	}
+
+	fmt.Fprintln(w)
+	if r.Node == -1 {
+		fmt.Fprintln(w, "No node can be recommended")
+	} else {
+		fmt.Fprintf(w, "Bootstrap %s\n", label(r.Node))
+	}
+	for _, reason := range r.Reasons {
+		fmt.Fprintf(w, "  - %s\n", reason)
+	}
+
+	if len(r.Warnings) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Warnings")
+	}
+	for _, warning := range r.Warnings {
+		if _, err := fmt.Fprintf(w, "  - %s\n", warning); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package timeline
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	clusterUUID = "f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1"
+	otherUUID   = "aaaaaaaa-31a3-11e7-908c-f7a5ad9e63b1"
+)
+
+func logged(uuid string, seqno int64) Position {
+	return Position{UUID: uuid, Seqno: seqno, Source: "log", Known: true}
+}
+
+func grastate(uuid string, seqno int64, safe bool) Position {
+	return Position{UUID: uuid, Seqno: seqno, Source: "grastate.dat", SafeToBootstrap: safe, Known: true}
+}
+
+func TestRecommendBootstrap(t *testing.T) {
+	tests := []struct {
+		name      string
+		logged    []Position
+		grastates map[int]Position
+		node      int
+		warnings  []string
+	}{
+		{
+			"highest seqno",
+			[]Position{logged(clusterUUID, 10), logged(clusterUUID, 12), logged(clusterUUID, 11)},
+			nil,
+			1,
+			nil,
+		},
+		{
+			"grastate.dat over the logs",
+			[]Position{logged(clusterUUID, 10), logged(clusterUUID, 12)},
+			map[int]Position{0: grastate(clusterUUID, 15, true)},
+			0,
+			nil,
+		},
+		{
+			"grastate.dat after a crash",
+			[]Position{logged(clusterUUID, 10), logged(clusterUUID, 12)},
+			map[int]Position{1: grastate(clusterUUID, -1, false)},
+			1,
+			[]string{"node 1: set safe_to_bootstrap: 1 in grastate.dat before bootstrapping it"},
+		},
+		{
+			"tie goes to safe_to_bootstrap",
+			[]Position{{}, {}},
+			map[int]Position{0: grastate(clusterUUID, 12, false), 1: grastate(clusterUUID, 12, true)},
+			1,
+			nil,
+		},
+		{
+			"not safe to bootstrap",
+			[]Position{{}, logged(clusterUUID, 10)},
+			map[int]Position{0: grastate(clusterUUID, 12, false)},
+			0,
+			[]string{"node 0: set safe_to_bootstrap: 1 in grastate.dat before bootstrapping it"},
+		},
+		{
+			"other history",
+			[]Position{logged(otherUUID, 99), logged(clusterUUID, 10), logged(clusterUUID, 11)},
+			nil,
+			2,
+			[]string{"node 0: UUID " + otherUUID + " does not match " + clusterUUID + " of the other nodes, its data is from a different history and it will need an SST"},
+		},
+		{
+			"unknown and unusable positions",
+			[]Position{{}, logged(zeroUUID, -1), logged(clusterUUID, -1), logged(clusterUUID, 10)},
+			nil,
+			3,
+			[]string{
+				"node 0: no position found, give its grastate.dat with --grastate or run mysqld --wsrep-recover",
+				"node 1: has no state (UUID " + zeroUUID + "), it will need an SST",
+				"node 2: seqno is -1 (log), it did not shut down cleanly. Run mysqld --wsrep-recover to find its position",
+			},
+		},
+		{
+			"nothing to compare",
+			[]Position{{}, logged(clusterUUID, -1)},
+			nil,
+			-1,
+			[]string{
+				"node 0: no position found, give its grastate.dat with --grastate or run mysqld --wsrep-recover",
+				"node 1: seqno is -1 (log), it did not shut down cleanly. Run mysqld --wsrep-recover to find its position",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := RecommendBootstrap(test.logged, test.grastates, nil)
+			if r.Node != test.node {
+				t.Errorf("node: got %d, want %d (%q)", r.Node, test.node, r.Reasons)
+			}
+			if !reflect.DeepEqual(r.Warnings, test.warnings) {
+				t.Errorf("warnings:\n got %q\nwant %q", r.Warnings, test.warnings)
+			}
+		})
+	}
+}
+
+func TestPositionTracker(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want string
+	}{
+		{
+			"recovered position",
+			"170614 10:00:00 mysqld_safe WSREP: Recovered position " + clusterUUID + ":40\n",
+			clusterUUID + ":40",
+		},
+		{
+			"recovered position by mysqld",
+			"2017-06-14 10:00:00 139993574066048 [Note] WSREP: Recovered position " + clusterUUID + ":40\n",
+			clusterUUID + ":40",
+		},
+		{
+			"recovered position by Galera 4",
+			"2021-03-01T10:00:00.000000Z 0 [System] [MY-000000] [WSREP] Recovered position: " + clusterUUID + ":40\n",
+			clusterUUID + ":40",
+		},
+		{
+			"shift once the node has the data",
+			`2017-06-14 10:00:00 1 [Note] WSREP: State transfer required:
+	Group state: ` + clusterUUID + `:30
+	Local state: 00000000-0000-0000-0000-000000000000:-1
+2017-06-14 10:00:01 1 [Note] WSREP: Shifting PRIMARY -> JOINER (TO: 30)
+2017-06-14 10:00:05 1 [Note] WSREP: Shifting JOINER -> JOINED (TO: 30)
+2017-06-14 10:00:06 1 [Note] WSREP: Shifting JOINED -> SYNCED (TO: 31)
+`,
+			clusterUUID + ":31",
+		},
+		{
+			"joiner without the data yet",
+			`2017-06-14 10:00:00 1 [Note] WSREP: State transfer required:
+	Group state: ` + clusterUUID + `:30
+	Local state: ` + clusterUUID + `:20
+2017-06-14 10:00:01 1 [Note] WSREP: Shifting PRIMARY -> JOINER (TO: 30)
+`,
+			clusterUUID + ":20",
+		},
+		{
+			"history from Galera 4 quorum results",
+			`2021-03-01T10:00:00.000000Z 0 [Note] [MY-000000] [Galera] Quorum results:
+	version    = 6,
+	component  = PRIMARY,
+	conf_id    = 2,
+	members    = 3/3 (joined/total),
+	act_id     = 12,
+	last_appl. = 0,
+	protocols  = 2/10/4 (gcs/repl/appl),
+	vote policy= 0,
+	group UUID = ` + clusterUUID + `
+2021-03-01T10:00:01.000000Z 0 [Note] [MY-000000] [Galera] Shifting SYNCED -> OPEN (TO: 12)
+`,
+			clusterUUID + ":12",
+		},
+		{
+			"no position",
+			"2017-06-14 10:00:01 1 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 12)\n",
+			"unknown",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events, warnings := NewParser().ParseReader(0, strings.NewReader(test.log))
+			if len(warnings) > 0 {
+				t.Fatalf("unexpected warnings %v", warnings)
+			}
+			positions := NewPositionTracker(1)
+			for _, e := range events {
+				positions.Track(e)
+			}
+			if got := positions.Positions[0].String(); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadGrastate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "grastate.dat")
+	err := ioutil.WriteFile(path, []byte(`# GALERA saved state
+version: 2.1
+uuid:    `+clusterUUID+`
+seqno:   -1
+safe_to_bootstrap: 1
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadGrastate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Position{UUID: clusterUUID, Seqno: -1, Source: path, SafeToBootstrap: true, Known: true}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
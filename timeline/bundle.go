@@ -0,0 +1,452 @@
+package timeline
+
+import (
+	"archive/tar"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// Logs written by the MySQL jobs in cf-mysql-release and pxc-release,
+	// including rotated and compressed copies, e.g. mysql.err.log.2.gz
+	logNameMatcher = regexp.MustCompile(`^(mysql\.err\.log|galera-init.*\.log|innobackup\..*\.log)`)
+
+	tarballNameMatcher = regexp.MustCompile(`\.(tar|tgz|tar\.gz|tar\.bz2|tar\.xz)$`)
+
+	// Directories the jobs log to. The directory above them is the VM.
+	jobDirs = map[string]bool{
+		"mysql":       true,
+		"pxc-mysql":   true,
+		"galera-init": true,
+	}
+
+	// Prefix of the job directories when a bundle contains full paths
+	boshLogDir = "var/vcap/sys/log"
+
+	// logrotate numbers old logs from 1, newest first, e.g. mysql.err.log.2.gz
+	rotationMatcher = regexp.MustCompile(`\.([0-9]+)(\.(gz|bz2|xz))?$`)
+
+	// A log compressed without being numbered, e.g. mysql.err.log.gz
+	compressionMatcher = regexp.MustCompile(`\.(gz|bz2|xz)$`)
+
+	// How far in to a log to look for its first timestamp
+	maxLinesBeforeTimestamp = 1000
+)
+
+// Node is a MySQL server in the cluster
+//   - Name given with --node, or found in the logs
+//   - Path given on the command line, or VM the logs were found under
+//   - Address and Galera UUIDs found in the logs
+//   - Addresses of the other nodes it connected to, by UUID
+//   - Logs it wrote
+type Node struct {
+	Name    string
+	Path    string
+	Address string
+	UUIDs   []string
+	Peers   map[string]string
+	Logs    []LogSource
+}
+
+// LogSource is a log file on disk or inside a tarball
+//   - Path of the file on disk
+//   - Path of the log inside the tarball, and inside any tarballs nested in that
+//   - Overview of the log, shared by copies of the source so it is only read once
+type LogSource struct {
+	Path     string
+	Members  []string
+	overview *logOverview
+}
+
+// logOverview is what a first read of a log tells us, without looking for
+// events. Only the lines that identify the node are kept, so it takes little
+// memory however long the log is.
+//   - First timestamp in the log, if there is one
+//   - Lines that identify the node, normalized
+type logOverview struct {
+	once     sync.Once
+	time     time.Time
+	known    bool
+	identity []string
+}
+
+func newLogSource(filePath string, members ...string) LogSource {
+	return LogSource{filePath, members, &logOverview{}}
+}
+
+func (s LogSource) String() string {
+	return strings.Join(append([]string{s.Path}, s.Members...), ":")
+}
+
+// Open the log for reading, extracting it from its tarball if needed
+func (s LogSource) Open() (io.ReadCloser, error) {
+	if len(s.Members) == 0 {
+		return openLog(s.Path)
+	}
+
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader = file
+	for _, member := range s.Members {
+		if reader, err = openMember(reader, member); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %s", s, err)
+		}
+	}
+
+	if reader, err = decompress(reader); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &logFile{reader, file}, nil
+}
+
+// firstTime returns the first timestamp in the log, used to put rotated logs
+// in order
+func (s LogSource) firstTime() (time.Time, bool) {
+	overview := s.readOverview()
+	return overview.time, overview.known
+}
+
+// readOverview reads the log through once. Opening a log in a tarball
+// decompresses the tarball up to it, so sources from DiscoverNodes only do it
+// once.
+func (s LogSource) readOverview() *logOverview {
+	if s.overview == nil {
+		overview := &logOverview{}
+		overview.read(s)
+		return overview
+	}
+	s.overview.once.Do(func() {
+		s.overview.read(s)
+	})
+	return s.overview
+}
+
+func (h *logOverview) read(s LogSource) {
+	// Any error opening or reading the log is reported when its events are
+	// parsed
+	reader, err := s.Open()
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+
+	scanner := newLineScanner(reader)
+	for i := 0; scanner.Scan(); i++ {
+		if !h.known && i < maxLinesBeforeTimestamp {
+			h.time, err = GetTimeAny(scanner.Text())
+			h.known = err == nil
+		}
+		if line := normalizeLine(scanner.Text()); identifies(line) {
+			h.identity = append(h.identity, line)
+		}
+	}
+}
+
+// rotation is the logrotate number of the log, 0 for the current log
+func (s LogSource) rotation() int {
+	name := s.Path
+	if len(s.Members) > 0 {
+		name = s.Members[len(s.Members)-1]
+	}
+
+	matches := rotationMatcher.FindStringSubmatch(name)
+	if matches == nil {
+		return 0
+	}
+	rotation, _ := strconv.Atoi(matches[1])
+	return rotation
+}
+
+// family is the log that the log was rotated from, e.g. mysql.err.log for
+// mysql.err.log.2.gz
+func (s LogSource) family() string {
+	name := rotationMatcher.ReplaceAllString(s.String(), "")
+	return compressionMatcher.ReplaceAllString(name, "")
+}
+
+// logFamilies groups the logs of a node by the log they were rotated from, in
+// the order each log is first found, with the segments of each sorted by
+// sortLogs. A node's logs are written at the same time, e.g. mysql.err.log
+// and innobackup.backup.log, so only the segments of one log follow on from
+// each other.
+func logFamilies(logs []LogSource) [][]LogSource {
+	var names []string
+	families := make(map[string][]LogSource)
+	for _, source := range logs {
+		name := source.family()
+		if _, ok := families[name]; !ok {
+			names = append(names, name)
+		}
+		families[name] = append(families[name], source)
+	}
+
+	var sorted [][]LogSource
+	for _, name := range names {
+		sorted = append(sorted, sortLogs(families[name]))
+	}
+	return sorted
+}
+
+// sortLogs puts the segments of a log in chronological order, by the first
+// timestamp in each, so they are read oldest first. Logs without
+// a timestamp, e.g. empty ones, keep their place in logrotate order.
+func sortLogs(logs []LogSource) []LogSource {
+	sorted := append([]LogSource{}, logs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].rotation() > sorted[j].rotation()
+	})
+
+	// Only the places of the logs with a timestamp are sorted between them
+	var places []int
+	var dated []LogSource
+	for i, source := range sorted {
+		if _, ok := source.firstTime(); ok {
+			places = append(places, i)
+			dated = append(dated, source)
+		}
+	}
+	sort.SliceStable(dated, func(i, j int) bool {
+		first, _ := dated[i].firstTime()
+		next, _ := dated[j].firstTime()
+		return first.Before(next)
+	})
+	for i, place := range places {
+		sorted[place] = dated[i]
+	}
+
+	return sorted
+}
+
+// openMember returns a reader for one file inside a (compressed) tarball
+func openMember(tarball io.Reader, member string) (io.Reader, error) {
+	reader, err := decompress(tarball)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s not found", member)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Name == member {
+			return archive, nil
+		}
+	}
+}
+
+// bundle collects the logs found in a tarball or directory, grouped by VM
+type bundle struct {
+	name string
+	vms  map[string][]LogSource
+}
+
+func (b *bundle) add(memberPath string, source LogSource) {
+	vm := path.Dir(path.Clean(memberPath))
+	if jobDirs[path.Base(vm)] {
+		vm = path.Dir(vm)
+	}
+	vm = strings.TrimSuffix(strings.TrimSuffix(vm, boshLogDir), "/")
+
+	name := b.name
+	if vm != "." && vm != "" {
+		name = vm
+	}
+
+	b.vms[name] = append(b.vms[name], source)
+}
+
+// nodes returns a node per VM, sorted by the VM's path so the order is the
+// same each time
+func (b *bundle) nodes() []Node {
+	var nodes []Node
+	for name, logs := range b.vms {
+		nodes = append(nodes, Node{Path: name, Logs: logs})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Path < nodes[j].Path
+	})
+	return nodes
+}
+
+// DiscoverNodes builds the node list from the command line. Each log file is
+// a node, in the order given, or a comma separated list or glob of log files
+// makes up one node. Tarballs and directories are searched for MySQL logs,
+// which are grouped in to a node per VM.
+func DiscoverNodes(args []string) ([]Node, error) {
+	var nodes []Node
+
+	for _, arg := range args {
+		paths, err := expandPaths(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(paths) > 1 {
+			var logs []LogSource
+			for _, filePath := range paths {
+				if info, err := os.Stat(filePath); err != nil {
+					return nil, err
+				} else if info.IsDir() || isTarball(filePath) {
+					return nil, fmt.Errorf("%s: %s is not a log file, bundles must be given separately", arg, filePath)
+				}
+				logs = append(logs, newLogSource(filePath))
+			}
+			nodes = append(nodes, Node{Path: arg, Logs: logs})
+			continue
+		}
+
+		filePath := paths[0]
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		b := &bundle{bundleName(filePath), make(map[string][]LogSource)}
+		if info.IsDir() {
+			err = b.addDir(filePath)
+		} else if isTarball(filePath) {
+			err = b.addTarball(LogSource{Path: filePath}, "")
+		} else {
+			nodes = append(nodes, Node{Path: filePath, Logs: []LogSource{newLogSource(filePath)}})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(b.vms) == 0 {
+			return nil, fmt.Errorf("%s: no MySQL logs found", filePath)
+		}
+		nodes = append(nodes, b.nodes()...)
+	}
+
+	return nodes, nil
+}
+
+// expandPaths splits a comma separated list of paths and expands any globs
+func expandPaths(arg string) ([]string, error) {
+	var paths []string
+	for _, pattern := range strings.Split(arg, ",") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", pattern, err)
+		}
+		if len(matches) == 0 {
+			// Not a glob, or it matches nothing. Either way os.Stat reports it.
+			matches = []string{pattern}
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// addDir adds the logs in a directory, including those inside any tarballs
+func (b *bundle) addDir(dir string) error {
+	return filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if logNameMatcher.MatchString(info.Name()) {
+			b.add(relPath, newLogSource(filePath))
+		} else if tarballNameMatcher.MatchString(info.Name()) {
+			return b.addTarball(LogSource{Path: filePath}, vmPrefix(relPath))
+		}
+		return nil
+	})
+}
+
+// addTarball adds the logs in a tarball, with prefix added to their paths.
+// Tarballs nested inside it, as found in the output of `bosh logs` for a
+// whole deployment, are searched too.
+func (b *bundle) addTarball(tarball LogSource, prefix string) error {
+	file, err := os.Open(tarball.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return b.addTar(file, tarball, prefix)
+}
+
+func (b *bundle) addTar(reader io.Reader, tarball LogSource, prefix string) error {
+	reader, err := decompress(reader)
+	if err != nil {
+		return fmt.Errorf("%s: %s", tarball, err)
+	}
+
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", tarball, err)
+		}
+		if !header.FileInfo().Mode().IsRegular() {
+			continue
+		}
+
+		member := newLogSource(tarball.Path, append(append([]string{}, tarball.Members...), header.Name)...)
+		name := path.Base(header.Name)
+
+		if logNameMatcher.MatchString(name) {
+			b.add(prefix+header.Name, member)
+		} else if tarballNameMatcher.MatchString(name) {
+			if err := b.addTar(archive, member, vmPrefix(prefix+header.Name)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// isTarball checks for the tar magic bytes, after decompressing if needed
+func isTarball(filePath string) bool {
+	file, err := openLog(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	// "ustar" at offset 257 of the first header
+	header, _ := bufio.NewReaderSize(file, 512).Peek(512)
+	return len(header) == 512 && string(header[257:262]) == "ustar"
+}
+
+// vmPrefix names the VMs at the top of a nested tarball after the tarball
+func vmPrefix(tarballPath string) string {
+	return path.Join(path.Dir(tarballPath), bundleName(tarballPath)) + "/"
+}
+
+// bundleName is the name of a tarball or directory without its extensions
+func bundleName(bundlePath string) string {
+	return tarballNameMatcher.ReplaceAllString(path.Base(filepath.ToSlash(bundlePath)), "")
+}
@@ -0,0 +1,355 @@
+package timeline
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeLog writes a log to the directory, gzipped if its name ends in .gz
+func writeLog(t *testing.T, dir string, name string, text string) string {
+	t.Helper()
+
+	data := []byte(text)
+	if filepath.Ext(name) == ".gz" {
+		var gzipped bytes.Buffer
+		writer := gzip.NewWriter(&gzipped)
+		writer.Write(data)
+		writer.Close()
+		data = gzipped.Bytes()
+	}
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLogSourceRotation(t *testing.T) {
+	tests := []struct {
+		source LogSource
+		want   int
+	}{
+		{newLogSource("mysql.err.log"), 0},
+		{newLogSource("mysql.err.log.1"), 1},
+		{newLogSource("mysql.err.log.12.gz"), 12},
+		{newLogSource("mysql.err.log.gz"), 0},
+		{newLogSource("mysql.0.tgz", "mysql/mysql.err.log.3.xz"), 3},
+		{newLogSource("mysql.2.tgz", "mysql/mysql.err.log"), 0},
+	}
+
+	for _, test := range tests {
+		if got := test.source.rotation(); got != test.want {
+			t.Errorf("%s: got %d, want %d", test.source, got, test.want)
+		}
+	}
+}
+
+func TestSortLogs(t *testing.T) {
+	dir := t.TempDir()
+	log := func(name string, text string) LogSource {
+		return newLogSource(writeLog(t, dir, name, text))
+	}
+
+	current := log("mysql.err.log", "2017-06-14 11:00:00 1 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 5)\n")
+	empty := log("mysql.err.log.1", "")
+	// The clock went back, so the older rotation has the earlier time
+	skewed := log("mysql.err.log.2.gz", "no timestamp yet\n2017-06-14 09:00:00 1 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 5)\n")
+	oldest := log("mysql.err.log.3.gz", "170614 10:00:00 mysqld_safe Starting mysqld daemon\n")
+
+	tests := []struct {
+		name string
+		logs []LogSource
+		want []LogSource
+	}{
+		{"logrotate order", []LogSource{current, oldest}, []LogSource{oldest, current}},
+		{"by first time", []LogSource{current, skewed, oldest}, []LogSource{skewed, oldest, current}},
+		{"undated log keeps its place", []LogSource{current, empty, skewed, oldest}, []LogSource{skewed, oldest, empty, current}},
+		{"single log", []LogSource{empty}, []LogSource{empty}},
+	}
+
+	for _, test := range tests {
+		got := sortLogs(test.logs)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	// The first times are kept once read, for the copies of each source
+	os.Remove(current.Path)
+	if first, ok := current.firstTime(); !ok || first.Hour() != 11 {
+		t.Errorf("first time: got %s %t, want the time read before the log was removed", first, ok)
+	}
+}
+
+func TestLogFamilies(t *testing.T) {
+	dir := t.TempDir()
+	log := func(name string, text string) LogSource {
+		return newLogSource(writeLog(t, dir, name, text))
+	}
+
+	errorLog := log("mysql.err.log", "2017-06-14 11:00:00 1 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 5)\n")
+	backupLog := log("innobackup.backup.log", "170614 10:30:00 innobackupex: Starting the backup operation\n")
+	rotated := log("mysql.err.log.1.gz", "2017-06-14 10:00:00 1 [Note] WSREP: Shifting OPEN -> SYNCED (TO: 5)\n")
+	compressed := log("galera-init.log.gz", "")
+
+	got := logFamilies([]LogSource{errorLog, backupLog, rotated, compressed})
+	want := [][]LogSource{{rotated, errorLog}, {backupLog}, {compressed}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %v\nwant %v", got, want)
+	}
+
+	families := []struct {
+		source LogSource
+		want   string
+	}{
+		{newLogSource("mysql.err.log.2.gz"), "mysql.err.log"},
+		{newLogSource("innobackup.backup.log.1"), "innobackup.backup.log"},
+		{newLogSource("mysql.0.tgz", "mysql/mysql.err.log.3.xz"), "mysql.0.tgz:mysql/mysql.err.log"},
+	}
+	for _, test := range families {
+		if got := test.source.family(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestNodeIdentify(t *testing.T) {
+	dir := t.TempDir()
+	log := func(name string, text string) LogSource {
+		return newLogSource(writeLog(t, dir, name, text))
+	}
+
+	filler := strings.Repeat("2017-06-14 10:00:00 1 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 5)\n", 10001)
+	// A gzip header cut short, the log is skipped
+	corrupt := filepath.Join(dir, "mysql.err.log.2.gz")
+	if err := ioutil.WriteFile(corrupt, []byte("\x1f\x8b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	node := Node{Logs: []LogSource{
+		newLogSource(corrupt),
+		log("mysql.err.log.1.gz", "2017-06-14 09:00:00 1 [Note] WSREP: Passing config to GCS: base_dir = /var/vcap/store/mysql/; base_host = 10.0.16.44; base_port = 4567;\n"+
+			"2017-06-14T09:00:00Z 0 [Note] [MY-000000] [Galera] My UUID: 1c21c3b4-5103-11e7-a4c8-2a3ec7fa3e4c\n"),
+		log("mysql.err.log", "2017-06-14 10:00:00 1 [Note] WSREP: wsrep_node_name = 'mysql-0'\n"+filler+
+			"2017-06-14 11:00:00 1 [Note] WSREP: My UUID: 8a7f3cd1-5103-11e7-a4c8-2a3ec7fa3e4c\n"),
+	}}
+
+	// Each log is read once, for sorting and identifying
+	node.Logs = sortLogs(node.Logs)
+	os.Remove(node.Logs[1].Path)
+
+	node.Identify()
+	if node.Name != "mysql-0" || node.Address != "10.0.16.44" {
+		t.Errorf("got %q at %q, want mysql-0 at 10.0.16.44", node.Name, node.Address)
+	}
+	// A restart far in to a log gives the node another UUID
+	if want := []string{"1c21c3b4", "8a7f3cd1"}; !reflect.DeepEqual(node.UUIDs, want) {
+		t.Errorf("got UUIDs %v, want %v", node.UUIDs, want)
+	}
+	if got := NewPlainFormatter([]Node{node}).NodeByUUID("8a7f3cd1"); got != node.Label() {
+		t.Errorf("got %q for the later UUID, want %q", got, node.Label())
+	}
+}
+
+// tarFile is a file to put in a tarball built by a test
+type tarFile struct {
+	name string
+	data []byte
+}
+
+// tarGz builds a gzipped tarball of the files
+func tarGz(t *testing.T, files ...tarFile) []byte {
+	t.Helper()
+
+	var tarball bytes.Buffer
+	gzipped := gzip.NewWriter(&tarball)
+	archive := tar.NewWriter(gzipped)
+	for _, f := range files {
+		if err := archive.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		archive.Write(f.data)
+	}
+	archive.Close()
+	gzipped.Close()
+	return tarball.Bytes()
+}
+
+// writeFile writes a file to the directory, making the directories above it
+func writeFile(t *testing.T, dir string, name string, data []byte) string {
+	t.Helper()
+
+	filePath := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestDiscoverNodes(t *testing.T) {
+	dir := t.TempDir()
+	log := []byte("2017-06-14 10:00:00 1 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 5)\n")
+	at := func(name string) string {
+		return filepath.Join(dir, filepath.FromSlash(name))
+	}
+
+	// `bosh logs` of one VM, with the logs under their job directories
+	vm := writeFile(t, dir, "mysql-0.tgz", tarGz(t,
+		tarFile{"./mysql/mysql.err.log", log},
+		tarFile{"./mysql/mysql.err.log.1.gz", log},
+		tarFile{"./galera-init/galera-init.log", log},
+		tarFile{"./mysql/slow_query.log", log},
+	))
+
+	// `bosh logs` of a whole deployment, a tarball of each VM's tarball
+	deployment := writeFile(t, dir, "deployment.tgz", tarGz(t,
+		tarFile{"mysql.0.tgz", tarGz(t, tarFile{"./mysql/mysql.err.log", log})},
+		tarFile{"mysql.1.tar.gz", tarGz(t, tarFile{"./pxc-mysql/mysql.err.log", log}, tarFile{"./pxc-mysql/innobackup.prepare.log", log})},
+	))
+
+	// Full paths, with and without a directory for each VM
+	fullPaths := writeFile(t, dir, "full.tgz", tarGz(t,
+		tarFile{"var/vcap/sys/log/mysql/mysql.err.log", log},
+	))
+	vmPaths := writeFile(t, dir, "vms.tgz", tarGz(t,
+		tarFile{"vm-a/var/vcap/sys/log/mysql/mysql.err.log", log},
+		tarFile{"vm-b/var/vcap/sys/log/pxc-mysql/mysql.err.log", log},
+	))
+
+	// A directory of VMs, one of them still a tarball
+	writeFile(t, dir, "bundle/mysql-1/mysql/mysql.err.log", log)
+	writeFile(t, dir, "bundle/mysql-1/mysql/mysql.err.log.1", log)
+	writeFile(t, dir, "bundle/mysql-1/mysql/other.log", log)
+	writeFile(t, dir, "bundle/mysql-2/var/vcap/sys/log/mysql/mysql.err.log", log)
+	writeFile(t, dir, "bundle/vms/mysql-3.tgz", tarGz(t, tarFile{"./mysql/mysql.err.log", log}))
+
+	// Logs of one node given as a glob or comma separated list
+	writeFile(t, dir, "rotated/mysql.err.log", log)
+	writeFile(t, dir, "rotated/mysql.err.log.1", log)
+	writeFile(t, dir, "rotated/innobackup.backup.log", log)
+
+	tests := []struct {
+		name string
+		args []string
+		want map[string][]string
+	}{
+		{
+			"VM tarball",
+			[]string{vm},
+			map[string][]string{"mysql-0": {
+				vm + ":./mysql/mysql.err.log",
+				vm + ":./mysql/mysql.err.log.1.gz",
+				vm + ":./galera-init/galera-init.log",
+			}},
+		},
+		{
+			"nested tarballs",
+			[]string{deployment},
+			map[string][]string{
+				"mysql.0": {deployment + ":mysql.0.tgz:./mysql/mysql.err.log"},
+				"mysql.1": {deployment + ":mysql.1.tar.gz:./pxc-mysql/mysql.err.log", deployment + ":mysql.1.tar.gz:./pxc-mysql/innobackup.prepare.log"},
+			},
+		},
+		{
+			"full paths",
+			[]string{fullPaths, vmPaths},
+			map[string][]string{
+				"full": {fullPaths + ":var/vcap/sys/log/mysql/mysql.err.log"},
+				"vm-a": {vmPaths + ":vm-a/var/vcap/sys/log/mysql/mysql.err.log"},
+				"vm-b": {vmPaths + ":vm-b/var/vcap/sys/log/pxc-mysql/mysql.err.log"},
+			},
+		},
+		{
+			"directory",
+			[]string{at("bundle")},
+			map[string][]string{
+				"mysql-1":     {at("bundle/mysql-1/mysql/mysql.err.log"), at("bundle/mysql-1/mysql/mysql.err.log.1")},
+				"mysql-2":     {at("bundle/mysql-2/var/vcap/sys/log/mysql/mysql.err.log")},
+				"vms/mysql-3": {at("bundle/vms/mysql-3.tgz") + ":./mysql/mysql.err.log"},
+			},
+		},
+		{
+			"glob",
+			[]string{at("rotated/mysql.err.log*")},
+			map[string][]string{at("rotated/mysql.err.log*"): {at("rotated/mysql.err.log"), at("rotated/mysql.err.log.1")}},
+		},
+		{
+			"comma separated",
+			[]string{at("rotated/mysql.err.log.1") + "," + at("rotated/innobackup.backup.log")},
+			map[string][]string{at("rotated/mysql.err.log.1") + "," + at("rotated/innobackup.backup.log"): {at("rotated/mysql.err.log.1"), at("rotated/innobackup.backup.log")}},
+		},
+		{
+			"log files",
+			[]string{at("rotated/mysql.err.log"), at("rotated/innobackup.backup.log")},
+			map[string][]string{
+				at("rotated/mysql.err.log"):         {at("rotated/mysql.err.log")},
+				at("rotated/innobackup.backup.log"): {at("rotated/innobackup.backup.log")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		nodes, err := DiscoverNodes(test.args)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		got := make(map[string][]string)
+		for _, node := range nodes {
+			for _, source := range node.Logs {
+				got[node.Path] = append(got[node.Path], source.String())
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got  %v\nwant %v", test.name, got, test.want)
+		}
+	}
+
+	// The nodes of a bundle are in the order of their VMs, after those before it
+	nodes, err := DiscoverNodes([]string{at("rotated/mysql.err.log"), at("bundle")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, node := range nodes {
+		order = append(order, node.Path)
+	}
+	if want := []string{at("rotated/mysql.err.log"), "mysql-1", "mysql-2", "vms/mysql-3"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("got nodes %v, want %v", order, want)
+	}
+}
+
+func TestDiscoverNodesErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "empty/notes.txt", []byte("no logs here"))
+	writeFile(t, dir, "logs/mysql.err.log", []byte(""))
+	writeFile(t, dir, "logs/mysql-0.tgz", tarGz(t, tarFile{"./mysql/mysql.err.log", nil}))
+
+	tests := []struct {
+		name string
+		arg  string
+		err  string
+	}{
+		{"no logs", filepath.Join(dir, "empty"), "no MySQL logs found"},
+		{"missing", filepath.Join(dir, "missing.log"), "no such file"},
+		{"bundle in a list", filepath.Join(dir, "logs", "*"), "bundles must be given separately"},
+	}
+
+	for _, test := range tests {
+		_, err := DiscoverNodes([]string{test.arg})
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+		}
+	}
+}
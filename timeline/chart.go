@@ -0,0 +1,260 @@
+package timeline
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/template"
+	"time"
+)
+
+// Size of the state chart, in pixels
+const (
+	chartWidth       = 1200
+	chartLabelWidth  = 240
+	chartMargin      = 20
+	chartLaneHeight  = 24
+	chartMarkerSpace = 16
+	chartAxisHeight  = 30
+	chartLegendRow   = 20
+)
+
+// Colours of the states on the chart, in the order they are shown in the legend
+var chartStates = []struct {
+	state  string
+	colour string
+}{
+	{"SYNCED", "#5cb85c"},
+	{"JOINED", "#a3d9a5"},
+	{"DONOR/DESYNCED", "#f0ad4e"},
+	{"DONOR", "#f0ad4e"},
+	{"JOINER", "#5bc0de"},
+	{"PRIMARY", "#2e6da4"},
+	{"OPEN", "#c0c0c0"},
+	{StateStarting, "#c5b3e6"},
+	{"CLOSED", "#8c8c8c"},
+	{"DESTROYED", "#6c6c6c"},
+	{StateDown, "#343a40"},
+	{"ERROR", "#d9534f"},
+}
+
+// Shapes of the markers on the chart, drawn around 0,0
+var chartMarkers = []struct {
+	kind  string
+	label string
+	shape string
+}{
+	{MarkerBootstrap, "Bootstrap", `<polygon points="-6,5 6,5 0,-6" fill="#6f42c1"/>`},
+	{MarkerSST, "SST", `<polygon points="0,-6 6,0 0,6 -6,0" fill="#0275d8"/>`},
+	{MarkerIST, "IST", `<polygon points="0,-6 6,0 0,6 -6,0" fill="#fff" stroke="#0275d8" stroke-width="2"/>`},
+	{MarkerNonPrimary, "NON_PRIM view", `<path d="M-5,-5 L5,5 M5,-5 L-5,5" stroke="#212529" stroke-width="2.5"/>`},
+	{MarkerFatal, "Fatal error", `<circle r="5.5" fill="#d9534f" stroke="#212529"/>`},
+}
+
+// Steps between the ticks on the time axis, the first that gives few enough is used
+var chartTickSteps = []time.Duration{
+	time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 10 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour,
+	24 * time.Hour, 2 * 24 * time.Hour, 7 * 24 * time.Hour, 14 * 24 * time.Hour, 28 * 24 * time.Hour,
+}
+
+const chartMaxTicks = 8
+
+// stateChart is the layout of a chart of the states of the nodes over time,
+// with a lane per node
+type stateChart struct {
+	states    *StateTracker
+	nodes     []Node
+	formatter *MessageFormatter
+	start     time.Time
+	end       time.Time
+	linked    map[*Event]bool
+}
+
+// writeStateChart draws the states the tracker found as an SVG. The markers
+// of linked events link to their rows in the HTML timeline, the others to
+// nothing as their rows were not written.
+func writeStateChart(w io.Writer, states *StateTracker, nodes []Node, linked map[*Event]bool) error {
+	c := stateChart{states: states, nodes: nodes, formatter: NewPlainFormatter(nodes), linked: linked}
+	c.start, c.end = states.Period()
+	if !c.end.After(c.start) {
+		c.end = c.start.Add(time.Minute)
+	}
+
+	lanes := len(nodes)
+	if len(states.Spans) > lanes {
+		lanes = len(states.Spans)
+	}
+	axisY := c.laneY(lanes) + 4
+	legendY := axisY + chartAxisHeight
+	height := legendY + 2*chartLegendRow + chartMargin/2
+
+	var svg bytes.Buffer
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Courier New, Courier, monospace" font-size="12">`+"\n", chartWidth, height, chartWidth, height)
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", chartWidth, height)
+
+	for i := 0; i < lanes; i++ {
+		c.writeLane(&svg, i)
+	}
+	for _, marker := range states.Markers {
+		c.writeMarker(&svg, marker)
+	}
+	c.writeAxis(&svg, axisY)
+	c.writeLegend(&svg, legendY)
+
+	svg.WriteString("</svg>\n")
+	_, err := w.Write(svg.Bytes())
+	return err
+}
+
+// laneY is the top of the lane of the node
+func (c *stateChart) laneY(node int) int {
+	return chartMargin/2 + node*(chartLaneHeight+chartMarkerSpace) + chartMarkerSpace
+}
+
+// x is where the time is on the chart
+func (c *stateChart) x(t time.Time) float64 {
+	plot := float64(chartWidth - chartLabelWidth - chartMargin)
+	return float64(chartLabelWidth) + plot*float64(t.Sub(c.start))/float64(c.end.Sub(c.start))
+}
+
+func (c *stateChart) writeLane(svg *bytes.Buffer, node int) {
+	y := c.laneY(node)
+
+	label := fmt.Sprintf("node %d", node)
+	if node < len(c.nodes) {
+		label = c.nodes[node].Label()
+	}
+	if runes := []rune(label); len(runes) > 30 {
+		label = string(runes[:29]) + "…"
+	}
+	fmt.Fprintf(svg, `<text x="%d" y="%d" dominant-baseline="middle">%s</text>`+"\n", chartMargin/2, y+chartLaneHeight/2, template.HTMLEscapeString(label))
+	fmt.Fprintf(svg, `<rect x="%d" y="%d" width="%d" height="%d" fill="#f8f9fa" stroke="#dee2e6"/>`+"\n", chartLabelWidth, y, chartWidth-chartLabelWidth-chartMargin, chartLaneHeight)
+
+	if node >= len(c.states.Spans) {
+		return
+	}
+	for _, span := range c.states.Spans[node] {
+		x := c.x(span.Start)
+		width := c.x(span.End) - x
+		if width < 1 {
+			width = 1
+		}
+		title := fmt.Sprintf("%s from %s to %s (%s)", span.State, span.Start.Format(timeFormatDefault), span.End.Format(timeFormatDefault), span.Duration())
+		fmt.Fprintf(svg, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"><title>%s</title></rect>`+"\n", x, y, width, chartLaneHeight, stateColour(span.State), template.HTMLEscapeString(title))
+	}
+}
+
+func (c *stateChart) writeMarker(svg *bytes.Buffer, marker Marker) {
+	shape := ""
+	for _, m := range chartMarkers {
+		if m.kind == marker.Kind {
+			shape = m.shape
+		}
+	}
+
+	e := marker.Event
+	x := c.x(e.Datetime)
+	y := c.laneY(e.Node)
+	title := fmt.Sprintf("%s %s", e.Datetime.Format(timeFormatDefault), c.formatter.Message(e))
+
+	link := c.linked[e]
+	if link {
+		fmt.Fprintf(svg, `<a href="#%s">`, filterFormatAnchor(e.Datetime.Format(timeFormatRow)))
+	}
+	fmt.Fprintf(svg, `<g transform="translate(%.1f,%d)"><title>%s</title><line y1="0" y2="%d" stroke="#212529" stroke-opacity="0.5"/>%s</g>`, x, y-chartMarkerSpace/2, template.HTMLEscapeString(title), chartLaneHeight+chartMarkerSpace/2, shape)
+	if link {
+		svg.WriteString(`</a>`)
+	}
+	svg.WriteString("\n")
+}
+
+func (c *stateChart) writeAxis(svg *bytes.Buffer, y int) {
+	span := c.end.Sub(c.start)
+	step := chartTickSteps[len(chartTickSteps)-1]
+	for _, s := range chartTickSteps {
+		if span/s <= chartMaxTicks {
+			step = s
+			break
+		}
+	}
+
+	format := "15:04"
+	switch {
+	case step >= 24*time.Hour:
+		format = "2006-01-02"
+	case c.start.YearDay() != c.end.YearDay() || c.start.Year() != c.end.Year():
+		format = "01-02 15:04"
+	case step < time.Minute:
+		format = "15:04:05"
+	}
+
+	fmt.Fprintf(svg, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#212529"/>`+"\n", chartLabelWidth, y, chartWidth-chartMargin, y)
+	for tick := c.start.Truncate(step); !tick.After(c.end); tick = tick.Add(step) {
+		if tick.Before(c.start) {
+			continue
+		}
+		x := c.x(tick)
+		fmt.Fprintf(svg, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#212529"/>`, x, y, x, y+5)
+		fmt.Fprintf(svg, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`+"\n", x, y+18, tick.Format(format))
+	}
+	fmt.Fprintf(svg, `<text x="%d" y="%d">%s</text>`+"\n", chartMargin/2, y+18, c.start.Format("2006-01-02"))
+}
+
+func (c *stateChart) writeLegend(svg *bytes.Buffer, y int) {
+	used := make(map[string]bool)
+	for _, spans := range c.states.Spans {
+		for _, span := range spans {
+			used[span.State] = true
+		}
+	}
+
+	x := chartLabelWidth
+	for _, s := range chartStates {
+		if !used[s.state] {
+			continue
+		}
+		fmt.Fprintf(svg, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/><text x="%d" y="%d">%s</text>`+"\n", x, y, s.colour, x+16, y+10, s.state)
+		x += 16 + 8*len(s.state) + 16
+	}
+
+	x = chartLabelWidth
+	y += chartLegendRow
+	for _, m := range chartMarkers {
+		fmt.Fprintf(svg, `<g transform="translate(%d,%d)">%s</g><text x="%d" y="%d">%s</text>`+"\n", x+6, y+6, m.shape, x+16, y+10, m.label)
+		x += 16 + 8*len(m.label) + 16
+	}
+}
+
+func stateColour(state string) string {
+	for _, s := range chartStates {
+		if s.state == state {
+			return s.colour
+		}
+	}
+	return "#ffffff"
+}
+
+// SVGRenderer draws a chart of the states of the nodes over time, with a lane
+// per node, rather than the events themselves. The states are tracked as the
+// events are read, unless they have been already.
+type SVGRenderer struct {
+	States *StateTracker
+}
+
+func (r SVGRenderer) Render(w io.Writer, events Stream, nodes []Node) error {
+	states := r.States
+	if states == nil {
+		states = NewStateTracker(len(nodes))
+		events = TrackStates(events, states)
+	}
+
+	for events.Next() {
+	}
+	if err := events.Err(); err != nil {
+		return err
+	}
+
+	return writeStateChart(w, states, nodes, nil)
+}
@@ -0,0 +1,65 @@
+package timeline
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	// Magic bytes at the start of compressed logs
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicBzip2 = []byte("BZh")
+	magicXz    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// logFile is an open log that may be read through a decompressor
+type logFile struct {
+	io.Reader
+	file *os.File
+}
+
+func (f *logFile) Close() error {
+	return f.file.Close()
+}
+
+// openLog opens a log for reading, decompressing it if it is gzip, bzip2 or xz
+func openLog(filePath string) (io.ReadCloser, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := decompress(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &logFile{reader, file}, nil
+}
+
+// decompress detects the compression used by r from its magic bytes and
+// returns a reader for the decompressed text. Plain text is passed through.
+func decompress(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+
+	// Short or empty files are not an error, they just can't be compressed
+	magic, _ := buffered.Peek(len(magicXz))
+
+	switch {
+	case bytes.HasPrefix(magic, magicGzip):
+		return gzip.NewReader(buffered)
+	case bytes.HasPrefix(magic, magicBzip2):
+		return bzip2.NewReader(buffered), nil
+	case bytes.HasPrefix(magic, magicXz):
+		return xz.NewReader(buffered)
+	}
+
+	return buffered, nil
+}
@@ -0,0 +1,68 @@
+package timeline
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ulikunitz/xz"
+)
+
+func TestDecompress(t *testing.T) {
+	text := "a log line\n"
+
+	var gzipped bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipped)
+	gzipWriter.Write([]byte(text))
+	gzipWriter.Close()
+
+	var xzipped bytes.Buffer
+	xzWriter, err := xz.NewWriter(&xzipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xzWriter.Write([]byte(text))
+	xzWriter.Close()
+
+	// bzip2 -9 of the text, the standard library can only read bzip2
+	bzipped := []byte{
+		0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xc3, 0x6c, 0x6f, 0xe8, 0x00, 0x00,
+		0x01, 0xd1, 0x00, 0x00, 0x10, 0x40, 0x00, 0x22, 0xa5, 0xa0, 0x00, 0x22, 0x06, 0x26, 0x42, 0x0c,
+		0x98, 0x80, 0x48, 0x72, 0x3a, 0x9b, 0xe2, 0xee, 0x48, 0xa7, 0x0a, 0x12, 0x18, 0x6d, 0x8d, 0xfd,
+		0x00,
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"plain", []byte(text), text},
+		{"gzip", gzipped.Bytes(), text},
+		{"bzip2", bzipped, text},
+		{"xz", xzipped.Bytes(), text},
+		{"empty", nil, ""},
+		{"shorter than the xz magic", []byte("BZ"), "BZ"},
+	}
+
+	for _, test := range tests {
+		reader, err := decompress(bytes.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		got, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	if _, err := decompress(bytes.NewReader(magicGzip)); err == nil {
+		t.Error("expected an error for a truncated gzip header")
+	}
+}
@@ -0,0 +1,249 @@
+package timeline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// Longest log line that can be read. A Slave SQL error or InnoDB deadlock
+	// can include a whole query, longer than bufio.Scanner's usual 64KB.
+	maxLineLength = 64 * 1024 * 1024
+)
+
+// Event is an interesting event that occurred in MySQL logs
+//   - When it happened
+//   - Which node in the cluster
+//   - Type of event, the Description of the matcher that found it
+//   - How much attention it needs
+//   - Values parsed from the log lines
+//   - Raw log lines
+//   - Log it was found in, the log's place among the node's logs, and the line
+//   - Lines before and after it in the log, with --context
+//   - How to describe it, from the matcher that found it
+type Event struct {
+	Datetime time.Time
+	Node     int
+	Type     string
+	Severity Severity
+	Fields   Fields
+	Raw      string
+	Source   string
+	File     int
+	Line     int
+	Before   []string
+	After    []string
+	format   func(*Event, *MessageFormatter) string
+}
+
+// EventMatcher represents whats needed to find an event MySQL logs
+//   - Description of event
+//   - Function to match the event signature
+//   - Function to convert the raw text to an event, or explain why it could not
+//   - Function to describe the event from its fields
+type EventMatcher struct {
+	Description string
+	Signature   string
+	Get         func(*Scanner) (*Event, error)
+	Format      func(*Event, *MessageFormatter) string
+}
+
+// Scanner is a line scanner that remembers the current line number and
+// every line consumed since Next, so a failed EventMatcher can be reported.
+// Text is the current line with MySQL 5.7+ subsystem tags written the MariaDB
+// way, so matchers parse one format, while the consumed lines are kept as
+// they were logged. With context it also keeps the lines read before the
+// current event, and gives the lines read after an event to it once it is
+// followed.
+type Scanner struct {
+	scanner  *bufio.Scanner
+	peeked   bool
+	lineNo   int
+	text     string
+	consumed []string
+	context  int
+	recent   []string
+	before   []string
+	pending  []*Event
+}
+
+// ParseWarning is an event that was matched but could not be parsed, or a log
+// that could not be read to the end or opened at all
+//   - Where it was found, line 0 if the log could not be opened
+//   - Which matcher failed, empty if the rest of the log could not be read
+//   - Why it failed
+type ParseWarning struct {
+	File    string
+	Line    int
+	Matcher string
+	Err     error
+}
+
+// NewEvent is used by an EventMatcher to build the event it found. The Parser
+// fills in the type and where it was found.
+func NewEvent(eventTime time.Time, node int, severity Severity, fields Fields, raw []string) *Event {
+	return &Event{
+		eventTime,
+		node,
+		"",
+		severity,
+		fields,
+		strings.Join(raw[:], "\n"),
+		"",
+		0,
+		0,
+		nil,
+		nil,
+		nil,
+	}
+
+}
+
+// precedes orders events by when they happened. Events at the same time are
+// kept in the order they were logged, by node, log and line, so the order
+// does not depend on how the logs were read.
+func (e *Event) precedes(other *Event) bool {
+	if !e.Datetime.Equal(other.Datetime) {
+		return e.Datetime.Before(other.Datetime)
+	}
+	if e.Node != other.Node {
+		return e.Node < other.Node
+	}
+	if e.File != other.File {
+		return e.File < other.File
+	}
+	return e.Line < other.Line
+}
+
+func (e *EventMatcher) Match(line string) bool {
+	return strings.Contains(line, e.Signature)
+}
+
+func newScanner(r io.Reader, context int) *Scanner {
+	return &Scanner{scanner: newLineScanner(r), context: context}
+}
+
+// newLineScanner is a bufio.Scanner that can read lines up to maxLineLength
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineLength)
+	return scanner
+}
+
+// Next starts a new event by advancing to the next line and forgetting
+// the lines consumed so far
+func (s *Scanner) Next() bool {
+	s.consumed = nil
+	s.before = append([]string{}, s.recent...)
+	return s.Scan()
+}
+
+// Scan advances to the next line of the current event
+func (s *Scanner) Scan() bool {
+	if s.peeked {
+		s.peeked = false
+	} else if !s.scanner.Scan() {
+		return false
+	}
+	s.lineNo++
+	s.text = normalizeLine(s.scanner.Text())
+	s.consumed = append(s.consumed, s.scanner.Text())
+
+	if s.context > 0 {
+		s.recent = append(s.recent, s.scanner.Text())
+		if len(s.recent) > s.context {
+			s.recent = s.recent[1:]
+		}
+
+		pending := s.pending[:0]
+		for _, event := range s.pending {
+			event.After = append(event.After, s.scanner.Text())
+			if len(event.After) < s.context {
+				pending = append(pending, event)
+			}
+		}
+		s.pending = pending
+	}
+
+	return true
+}
+
+// peek returns the line after the current one without moving on to it, so
+// an event can end before a line that is not part of it
+func (s *Scanner) peek() (string, bool) {
+	if !s.peeked {
+		if !s.scanner.Scan() {
+			return "", false
+		}
+		s.peeked = true
+	}
+	return s.scanner.Text(), true
+}
+
+// follow records the context around an event that was read since Next: the
+// lines before it now, and the lines after it as they are scanned
+func (s *Scanner) follow(event *Event) {
+	if s.context == 0 {
+		return
+	}
+	event.Before = s.before
+	s.pending = append(s.pending, event)
+}
+
+func (s *Scanner) Text() string {
+	return s.text
+}
+
+func (s *Scanner) LineNo() int {
+	return s.lineNo
+}
+
+func (s *Scanner) Consumed() []string {
+	return s.consumed
+}
+
+// Err is why the log could not be read to the end, if it couldn't
+func (s *Scanner) Err() error {
+	return s.scanner.Err()
+}
+
+func (w ParseWarning) String() string {
+	if w.Matcher == "" && w.Line == 0 {
+		return fmt.Sprintf("%s: could not be read: %s", w.File, w.Err)
+	}
+	if w.Matcher == "" {
+		return fmt.Sprintf("%s:%d: stopped reading: %s", w.File, w.Line, w.Err)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", w.File, w.Line, w.Matcher, w.Err)
+}
+
+// truncateLines shortens each line longer than max bytes, saying how much was
+// cut. A max of 0 keeps the lines whole.
+func truncateLines(lines []string, max int) []string {
+	if max <= 0 {
+		return lines
+	}
+
+	truncated := make([]string, len(lines))
+	for i, line := range lines {
+		truncated[i] = truncateLine(line, max)
+	}
+	return truncated
+}
+
+// truncateLine shortens a line longer than max bytes, at the start of a
+// character
+func truncateLine(line string, max int) string {
+	if max <= 0 || len(line) <= max {
+		return line
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s... [%d bytes truncated]", line[:cut], len(line)-cut)
+}
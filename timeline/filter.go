@@ -0,0 +1,52 @@
+package timeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EventFilter chooses the events to keep, by their type and severity
+//   - Types to keep, the Description of their matchers, or all types if empty
+//   - Types to drop
+//   - Least severity to keep
+type EventFilter struct {
+	Include     []string
+	Exclude     []string
+	MinSeverity Severity
+}
+
+// Keep is true if the event passes the filter, for Timeline.Filter and
+// FilterStream. Types are compared ignoring case.
+func (f EventFilter) Keep(e *Event) bool {
+	if e.Severity < f.MinSeverity {
+		return false
+	}
+	if len(f.Include) > 0 && !containsFold(f.Include, e.Type) {
+		return false
+	}
+	return !containsFold(f.Exclude, e.Type)
+}
+
+// Check makes sure the types in the filter are found by the parser, so a
+// typo doesn't silently drop or keep everything
+func (f EventFilter) Check(p *Parser) error {
+	var types []string
+	for _, eventMatcher := range p.Matchers() {
+		types = append(types, eventMatcher.Description)
+	}
+	for _, name := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if !containsFold(types, name) {
+			return fmt.Errorf("unknown event type %q", name)
+		}
+	}
+	return nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
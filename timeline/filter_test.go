@@ -0,0 +1,59 @@
+package timeline
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventFilterKeep(t *testing.T) {
+	event := func(eventType string, severity Severity) *Event {
+		e := NewEvent(time.Date(2017, 6, 14, 10, 0, 0, 0, time.UTC), 0, severity, Fields{}, []string{""})
+		e.Type = eventType
+		return e
+	}
+	shift := event("Node is changing state", SeverityInfo)
+	crash := event("Assertion Failure", SeverityDanger)
+	unparsed := event("Cluster View", SeverityWarning)
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   []bool
+	}{
+		{"no filter", EventFilter{}, []bool{true, true, true}},
+		{"include", EventFilter{Include: []string{"Assertion Failure"}}, []bool{false, true, false}},
+		{"include several", EventFilter{Include: []string{"Assertion Failure", "Cluster View"}}, []bool{false, true, true}},
+		{"exclude", EventFilter{Exclude: []string{"Node is changing state"}}, []bool{false, true, true}},
+		{"include and exclude", EventFilter{Include: []string{"Cluster View"}, Exclude: []string{"Cluster View"}}, []bool{false, false, false}},
+		{"ignoring case", EventFilter{Include: []string{"assertion failure", "NODE IS CHANGING STATE"}}, []bool{true, true, false}},
+		{"min severity", EventFilter{MinSeverity: SeverityWarning}, []bool{false, true, true}},
+		{"min severity and include", EventFilter{Include: []string{"node is changing state"}, MinSeverity: SeverityWarning}, []bool{false, false, false}},
+	}
+
+	for _, test := range tests {
+		for i, e := range []*Event{shift, crash, unparsed} {
+			if got := test.filter.Keep(e); got != test.want[i] {
+				t.Errorf("%s: %s: got %t, want %t", test.name, e.Type, got, test.want[i])
+			}
+		}
+	}
+}
+
+func TestEventFilterCheck(t *testing.T) {
+	tests := []struct {
+		filter  EventFilter
+		wantErr bool
+	}{
+		{EventFilter{}, false},
+		{EventFilter{Include: []string{"Assertion Failure"}, Exclude: []string{"cluster view"}}, false},
+		{EventFilter{Include: []string{"Assertion Failures"}}, true},
+		{EventFilter{Exclude: []string{"Shifting"}}, true},
+	}
+
+	for _, test := range tests {
+		err := test.filter.Check(NewParser())
+		if (err != nil) != test.wantErr {
+			t.Errorf("%+v: got error %v, want error %t", test.filter, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package timeline
+
+import (
+	"bytes"
+	_ "embed"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// Markup of dangerous and successful parts of a message, until it has been
+// escaped. Control characters that HTML escaping leaves alone.
+var htmlMarkup = strings.NewReplacer(
+	"\x02", "<danger>", "\x03", "</danger>",
+	"\x04", "<success>", "\x05", "</success>",
+)
+
+// htmlMessage builds the message for an event as HTML. Messages include text
+// from the logs, e.g. the error of a Fatal Error or the fields of a rule, so
+// the whole message is escaped before the parts that need attention are
+// marked up.
+func htmlMessage(nodes []Node) func(*Event) string {
+	f := NewMessageFormatter(
+		func(s string) string { return "\x02" + s + "\x03" },
+		func(s string) string { return "\x04" + s + "\x05" },
+		nodes,
+	)
+	return func(e *Event) string {
+		return htmlMarkup.Replace(template.HTMLEscapeString(f.Message(e)))
+	}
+}
+
+func filterFormatAnchor(anchor string) string {
+	anchor = strings.Replace(anchor, "-", "", -1)
+	anchor = strings.Replace(anchor, ":", "", -1)
+	anchor = strings.Replace(anchor, ".", "", -1)
+	anchor = strings.Replace(anchor, " ", "_", -1)
+	return anchor
+}
+
+// Styles and script for the HTML timeline, embedded so it works offline
+var (
+	//go:embed assets/timeline.css
+	timelineStylesheet string
+
+	//go:embed assets/timeline.js
+	timelineScript string
+)
+
+// HTMLRenderer writes the timeline as a standalone HTML page, with a row per
+// timestamp and a column per node, under a chart of the states of the nodes
+// and a summary of the incident.
+// The styles are embedded unless CDN is set, which links to Bootstrap instead
+// for a smaller file. The states are tracked as the events are read, unless
+// they have been already.
+type HTMLRenderer struct {
+	CDN    bool
+	States *StateTracker
+}
+
+func (r HTMLRenderer) Render(w io.Writer, events Stream, nodes []Node) error {
+
+	// Written a row at a time, so the timeline never has to fit in memory
+	var tmplTimelineCols = `{{define "Header"}}
+<html>
+<head>
+{{ if .CDN }}
+<link rel="stylesheet" href="https://maxcdn.bootstrapcdn.com/bootstrap/4.0.0/css/bootstrap.min.css" integrity="sha384-Gn5384xqQ1aoWXA+058RXPxPg6fy4IWvTNh0E263XmFcJlSAwiGgFAW/dAiS6JXm" crossorigin="anonymous">
+{{ else }}
+<style>
+{{ .Stylesheet }}
+</style>
+{{ end }}
+<style>
+body{ font-family: Courier New, Courier, monospace; }
+th { font-size: 10pt; vertical-align: top; }
+td { font-size: 10pt; white-space: pre-wrap; vertical-align: top; }
+.nowrap { white-space: nowrap; }
+success { color: #5cb85c; font-weight: bold; }
+danger { color: #d9534f; font-weight: bold; }
+summary { cursor: pointer; }
+pre.raw .context { color: #6c757d; }
+pre.raw { margin: 0.25rem 0 0.5rem 0; padding: 0.25rem; font-size: 9pt; white-space: pre-wrap; background-color: #f8f9fa; border: 1px solid #dee2e6; }
+body { display: flex; flex-direction: column; }
+.state-chart { order: -2; overflow-x: auto; }
+.summary { order: -1; margin: 0 0.5rem; }
+.summary h6 { margin: 0.5rem 0 0 0; font-size: 11pt; }
+.summary ul { margin: 0; font-size: 10pt; }
+</style>
+
+<script>
+{{ .Script }}
+</script>
+
+</head>
+<body onload="triggers(); populateTRS(); expandAll();">
+<div>
+<button type="button" class="btn btn-info"  onclick="hideSelected();">Hide Selected</button>
+<button type="button" class="btn btn-info"  onclick="expandAll();">Expand</button>
+</div>
+<table class="table table-bordered table-condensed">
+<thead>
+<th class="align-top">Timestamp</th>
+{{ range $node := .Nodes }}
+<th class="align-top">{{ $node.Label | Escape }}</th>
+{{ end }}
+</thead>
+<tbody>
+{{end}}{{define "Row"}}
+<tr class="collapse">
+<td class="nowrap"><a name="{{ .Time | FormatAnchor }}" href="#{{ .Time | FormatAnchor }}">{{ .Time }}</td>
+{{ range $node := .Columns }}
+<td>{{ range $event := $node }}<details><summary>{{ $event | Message }}</summary><pre class="raw">{{ $event.Source | Escape }}:{{ $event.Line }}
+{{ range $line := $event.Before }}<span class="context">{{ $line | Escape }}</span>
+{{ end }}{{ $event.Raw | Escape }}{{ range $line := $event.After }}
+<span class="context">{{ $line | Escape }}</span>{{ end }}</pre></details>{{ end }}</td>
+{{ end }}
+</tr>
+{{end}}{{define "Footer"}}
+</tbody>
+</table>
+<div class="state-chart">
+{{ .Chart }}</div>
+<div class="summary">
+{{ range $section := .Summary }}<h6>{{ $section.Title | Escape }}</h6>
+<ul>{{ range $line := $section.Lines }}<li>{{ $line | Escape }}</li>{{ else }}<li>none</li>{{ end }}</ul>
+{{ end }}</div>
+</body>
+</html>
+{{end}}`
+
+	filters := template.FuncMap{
+		"FormatAnchor": filterFormatAnchor,
+		"Message":      htmlMessage(nodes),
+		"Escape":       template.HTMLEscapeString,
+	}
+
+	t, err := template.New("foo").Funcs(filters).Parse(tmplTimelineCols)
+	if err != nil {
+		return err
+	}
+
+	type headerData struct {
+		Nodes      []Node
+		CDN        bool
+		Stylesheet string
+		Script     string
+	}
+
+	header := headerData{
+		nodes,
+		r.CDN,
+		timelineStylesheet,
+		timelineScript,
+	}
+	if err := t.ExecuteTemplate(w, "Header", header); err != nil {
+		return err
+	}
+
+	// The chart is drawn once all the events have been read, and shown
+	// above them by the stylesheet
+	states := r.States
+	if states == nil {
+		states = NewStateTracker(len(nodes))
+		events = TrackStates(events, states)
+	}
+
+	// The states may have been tracked before the events were filtered, so
+	// only the markers of events that are written link to their rows. A
+	// marker is tracked before its event gets here.
+	markers := make(map[*Event]bool)
+	tracked := 0
+	linked := make(map[*Event]bool)
+	rows := newRowStream(events, len(nodes))
+	for rows.Next() {
+		for _, marker := range states.Markers[tracked:] {
+			markers[marker.Event] = true
+		}
+		tracked = len(states.Markers)
+		for _, column := range rows.Columns {
+			for _, event := range column {
+				if markers[event] {
+					linked[event] = true
+				}
+			}
+		}
+		if err := t.ExecuteTemplate(w, "Row", rows); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var chart bytes.Buffer
+	if err := writeStateChart(&chart, states, nodes, linked); err != nil {
+		return err
+	}
+
+	type footerData struct {
+		Chart   string
+		Summary []summarySection
+	}
+
+	footer := footerData{
+		chart.String(),
+		Summarize(states, nodes).sections(nodes),
+	}
+	return t.ExecuteTemplate(w, "Footer", footer)
+}
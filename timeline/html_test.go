@@ -0,0 +1,89 @@
+package timeline
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHTMLMarkerLinks(t *testing.T) {
+	log := `2017-06-14 10:00:00 1 [Note] WSREP: 'wsrep-new-cluster' option used, bootstrapping the cluster
+2017-06-14 10:00:02 1 [Note] WSREP: IST received: f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1:10
+`
+	bootstrap := `<a href="#` + filterFormatAnchor("2017-06-14 10:00:00") + `">`
+	ist := `<a href="#` + filterFormatAnchor("2017-06-14 10:00:02") + `">`
+
+	tests := []struct {
+		name  string
+		keep  func(*Event) bool
+		links []string
+	}{
+		{"all written", func(*Event) bool { return true }, []string{bootstrap, ist}},
+		{"bootstrap filtered", func(e *Event) bool { return e.Datetime.Second() != 0 }, []string{ist}},
+		{"none written", func(*Event) bool { return false }, nil},
+	}
+
+	for _, test := range tests {
+		events, _ := NewParser().ParseReader(0, strings.NewReader(log))
+		states := NewStateTracker(1)
+		stream := FilterStream(TrackStates(events.Stream(), states), test.keep)
+
+		var html bytes.Buffer
+		if err := (HTMLRenderer{States: states}).Render(&html, stream, []Node{{Name: "mysql-0"}}); err != nil {
+			t.Fatal(err)
+		}
+		chart := html.String()[strings.Index(html.String(), "<svg"):]
+
+		if len(states.Markers) != 2 {
+			t.Fatalf("%s: got %d markers, want the bootstrap and IST tracked before the filter", test.name, len(states.Markers))
+		}
+		if got := strings.Count(chart, `<a href=`); got != len(test.links) {
+			t.Errorf("%s: got %d links, want %d", test.name, got, len(test.links))
+		}
+		for _, link := range test.links {
+			if !strings.Contains(chart, link) {
+				t.Errorf("%s: no %s in the chart", test.name, link)
+			}
+		}
+	}
+}
+
+func TestHTMLEscaping(t *testing.T) {
+	log := `2017-06-14 10:00:00 1 [ERROR] Fatal error: <script>alert("x")</script> & more
+2017-06-14 10:00:01 1 [Note] WSREP: Running: 'wsrep_sst_xtrabackup-v2 --role 'donor' --address '10.0.16.45:4444/xtrabackup_sst//1' --socket '/tmp/mysql.sock' '
+`
+	nodes := []Node{{Name: "mysql<0>", Address: "10.0.16.44"}, {Name: "mysql<1>", Address: "10.0.16.45"}}
+	events, _ := NewParser().ParseReader(0, strings.NewReader(log))
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+
+	tests := []struct {
+		event *Event
+		want  string
+	}{
+		{events[0], "<danger>Fatal Error: &lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; more</danger>"},
+		{events[1], "Donating to node mysql&lt;1&gt; (10.0.16.45) via SST"},
+	}
+	message := htmlMessage(nodes)
+	for _, test := range tests {
+		if got := message(test.event); got != test.want {
+			t.Errorf("%s: got  %q\nwant %q", test.event.Type, got, test.want)
+		}
+	}
+
+	var html bytes.Buffer
+	if err := (HTMLRenderer{}).Render(&html, events.Stream(), nodes); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range append([]string{tests[0].want, tests[1].want}, "mysql&lt;0&gt; (10.0.16.44)") {
+		if !strings.Contains(html.String(), want) {
+			t.Errorf("no %q in the HTML", want)
+		}
+	}
+	for _, unescaped := range []string{"<script>alert", "mysql<0>", "mysql<1>"} {
+		if strings.Contains(html.String(), unescaped) {
+			t.Errorf("%q is not escaped in the HTML", unescaped)
+		}
+	}
+}
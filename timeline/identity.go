@@ -0,0 +1,172 @@
+package timeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// identityMatcher finds a line that tells us something about the node that
+// wrote the log
+//   - Substring to look for before trying the regular expression
+//   - Regular expression capturing the value
+//   - Function to record the value on the node
+type identityMatcher struct {
+	Signature string
+	Matcher   *regexp.Regexp
+	Set       func(*Node, string)
+}
+
+var identityMatchers = []identityMatcher{
+	{
+		// 2017-06-14 14:21:49 140348199405440 [Note] WSREP: Passing config to GCS: base_dir = /var/vcap/store/mysql/; base_host = 10.0.16.44; base_port = 4567; ...
+		"base_host = ",
+		regexp.MustCompile(`base_host = ([^;]+);`),
+		func(n *Node, address string) {
+			n.Address = address
+		},
+	},
+	{
+		// 2017-06-14 19:10:58 140682204215040 [Note] WSREP: Running: 'wsrep_sst_xtrabackup-v2 --role 'joiner' --address '10.19.148.90' ...
+		// The joiner is given its own address, the donor is given the joiner's
+		"--role 'joiner'",
+		regexp.MustCompile(`--role 'joiner' --address '([^':]+)`),
+		func(n *Node, address string) {
+			if n.Address == "" {
+				n.Address = address
+			}
+		},
+	},
+	{
+		// 2017-06-14 14:21:49 140348199405440 [Note] WSREP: wsrep_node_name = 'mysql-0'
+		"wsrep_node_name",
+		regexp.MustCompile(`wsrep_node_name\W+([A-Za-z0-9._-]+)`),
+		func(n *Node, name string) {
+			if n.Name == "" {
+				n.Name = name
+			}
+		},
+	},
+	{
+		// 2017-06-14 14:21:50 140348199405440 [Note] WSREP: My UUID: 1c21c3b4-5103-11e7-a4c8-2a3ec7fa3e4c
+		"My UUID: ",
+		regexp.MustCompile(`My UUID: ([0-9a-f-]+)`),
+		func(n *Node, uuid string) {
+			n.addUUID(uuid)
+		},
+	},
+	{
+		// 2017-06-14 14:21:50 140348199405440 [Note] WSREP: (1c21c3b4, 'tcp://0.0.0.0:4567') listening at tcp://0.0.0.0:4567
+		", 'tcp://",
+		regexp.MustCompile(`\(([0-9a-f]{8})(?:-[0-9a-f]{4})?, 'tcp://`),
+		func(n *Node, uuid string) {
+			n.addUUID(uuid)
+		},
+	},
+	{
+		// 2017-06-14 14:21:52 140348199405440 [Note] WSREP: (1c21c3b4, 'tcp://0.0.0.0:4567') connection established to 8a7f3cd1 tcp://10.0.16.45:4567
+		// Galera 4 writes the UUID as 0e5b7dee-8b4d, it is kept by its first 8 characters
+		"connection established to ",
+		regexp.MustCompile(`connection established to ([0-9a-f]{8}(?:-[0-9a-f]{4})? tcp://[^:]+)`),
+		func(n *Node, peer string) {
+			parts := strings.SplitN(peer, " tcp://", 2)
+			if n.Peers == nil {
+				n.Peers = make(map[string]string)
+			}
+			n.Peers[parts[0][:8]] = parts[1]
+		},
+	},
+}
+
+// identifies checks if the line may tell us something about the node
+func identifies(line string) bool {
+	for _, identityMatcher := range identityMatchers {
+		if strings.Contains(line, identityMatcher.Signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// identify records anything the line reveals about the node
+func (n *Node) identify(line string) {
+	for _, identityMatcher := range identityMatchers {
+		if !strings.Contains(line, identityMatcher.Signature) {
+			continue
+		}
+		if matches := identityMatcher.Matcher.FindStringSubmatch(line); matches != nil {
+			identityMatcher.Set(n, strings.TrimSpace(matches[1]))
+		}
+	}
+}
+
+// Identify reads the logs of the node for its name, address and Galera UUIDs,
+// without looking for events, so they are known before its events are
+// streamed. Each restart gives the node a new UUID, so the whole of each log
+// is read. A log that can't be read is skipped, it is reported when its
+// events are parsed.
+func (n *Node) Identify() {
+	for _, family := range logFamilies(n.Logs) {
+		for _, source := range family {
+			for _, line := range source.readOverview().identity {
+				n.identify(line)
+			}
+		}
+	}
+}
+
+// addUUID records a Galera UUID of the node. A node gets a new UUID each time
+// it starts, and views only show the first 8 characters of it.
+func (n *Node) addUUID(uuid string) {
+	if len(uuid) > 8 {
+		uuid = uuid[:8]
+	}
+	for _, known := range n.UUIDs {
+		if known == uuid {
+			return
+		}
+	}
+	n.UUIDs = append(n.UUIDs, uuid)
+}
+
+// Label is how the node is shown, e.g. "mysql-0 (10.0.16.44)"
+func (n Node) Label() string {
+	name := n.Name
+	if name == "" {
+		name = n.Path
+	}
+	if n.Address == "" || n.Address == name {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, n.Address)
+}
+
+// nodeByAddress finds the node with the address, or -1
+func nodeByAddress(nodes []Node, address string) int {
+	for i, n := range nodes {
+		if n.Address == address {
+			return i
+		}
+	}
+	return -1
+}
+
+// nodeNamesByUUID maps the UUIDs found in all the logs to node labels. A
+// node's own UUIDs are in its log, other nodes' logs tell us which address
+// each UUID connected from.
+func nodeNamesByUUID(nodes []Node) map[string]string {
+	names := make(map[string]string)
+	for _, n := range nodes {
+		for uuid, address := range n.Peers {
+			if i := nodeByAddress(nodes, address); i >= 0 {
+				names[uuid] = nodes[i].Label()
+			}
+		}
+	}
+	for _, n := range nodes {
+		for _, uuid := range n.UUIDs {
+			names[uuid] = n.Label()
+		}
+	}
+	return names
+}
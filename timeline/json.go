@@ -0,0 +1,109 @@
+package timeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+)
+
+// jsonEvent is an Event as written by the json and ndjson formats
+type jsonEvent struct {
+	Time     time.Time `json:"time"`
+	Node     int       `json:"node"`
+	NodeName string    `json:"node_name"`
+	Type     string    `json:"type"`
+	Severity Severity  `json:"severity"`
+	Message  string    `json:"message"`
+	Fields   Fields    `json:"fields"`
+	Raw      []string  `json:"raw"`
+	Source   string    `json:"source"`
+	Line     int       `json:"line"`
+	Before   []string  `json:"before,omitempty"`
+	After    []string  `json:"after,omitempty"`
+}
+
+func newJSONEvent(event *Event, nodes []Node, formatter *MessageFormatter) (jsonEvent, error) {
+	if err := checkNode(event, len(nodes)); err != nil {
+		return jsonEvent{}, err
+	}
+	return jsonEvent{
+		event.Datetime,
+		event.Node,
+		nodes[event.Node].Label(),
+		event.Type,
+		event.Severity,
+		formatter.Message(event),
+		event.Fields,
+		strings.Split(event.Raw, "\n"),
+		event.Source,
+		event.Line,
+		event.Before,
+		event.After,
+	}, nil
+}
+
+// JSONRenderer writes the timeline as a single JSON array
+type JSONRenderer struct{}
+
+func (r JSONRenderer) Render(w io.Writer, events Stream, nodes []Node) error {
+	formatter := NewPlainFormatter(nodes)
+
+	// Each event is written as it is read, indented as if the whole array
+	// had been encoded at once
+	var event bytes.Buffer
+	encoder := json.NewEncoder(&event)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("  ", "  ")
+
+	separator := "[\n  "
+	for events.Next() {
+		event.Reset()
+		jsonEvent, err := newJSONEvent(events.Event(), nodes, formatter)
+		if err != nil {
+			return err
+		}
+		if err := encoder.Encode(jsonEvent); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+		if _, err := w.Write(bytes.TrimSuffix(event.Bytes(), []byte("\n"))); err != nil {
+			return err
+		}
+		separator = ",\n  "
+	}
+	if err := events.Err(); err != nil {
+		return err
+	}
+
+	end := "\n]\n"
+	if separator == "[\n  " {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(w, end)
+	return err
+}
+
+// NDJSONRenderer writes the timeline as one JSON object per line, for jq and
+// other tools that stream
+type NDJSONRenderer struct{}
+
+func (r NDJSONRenderer) Render(w io.Writer, events Stream, nodes []Node) error {
+	formatter := NewPlainFormatter(nodes)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for events.Next() {
+		event, err := newJSONEvent(events.Event(), nodes, formatter)
+		if err != nil {
+			return err
+		}
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return events.Err()
+}
@@ -0,0 +1,127 @@
+package timeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decodedEvent is what a consumer of the json format reads back
+type decodedEvent struct {
+	Time     string                 `json:"time"`
+	Node     int                    `json:"node"`
+	NodeName string                 `json:"node_name"`
+	Type     string                 `json:"type"`
+	Severity string                 `json:"severity"`
+	Message  string                 `json:"message"`
+	Fields   map[string]interface{} `json:"fields"`
+	Raw      []string               `json:"raw"`
+	Source   string                 `json:"source"`
+	Line     int                    `json:"line"`
+	Before   []string               `json:"before"`
+	After    []string               `json:"after"`
+}
+
+func TestJSONRenderer(t *testing.T) {
+	log := `2017-06-14 10:00:00 1 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 5)
+2017-06-14 10:00:01 1 [Note] WSREP: State transfer required:
+	Group state: f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1:30
+	Local state: f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1:-1
+`
+	nodes := []Node{{Name: "mysql-0", Address: "10.0.16.44"}, {Name: "mysql-1", Address: "10.0.16.45"}}
+	events, _ := NewParser().ParseReader(1, strings.NewReader(log))
+	for _, e := range events {
+		e.Source = "mysql.err.log"
+	}
+
+	want := []decodedEvent{
+		{
+			Time:     "2017-06-14T10:00:00Z",
+			Node:     1,
+			NodeName: "mysql-1 (10.0.16.45)",
+			Type:     "Node is changing state",
+			Severity: "danger",
+			Message:  "Shifting: SYNCED to OPEN",
+			Fields:   map[string]interface{}{"from": "SYNCED", "to": "OPEN", "seqno": float64(5)},
+			Raw:      []string{"2017-06-14 10:00:00 1 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 5)"},
+			Source:   "mysql.err.log",
+			Line:     1,
+		},
+		{
+			Time:     "2017-06-14T10:00:01Z",
+			Node:     1,
+			NodeName: "mysql-1 (10.0.16.45)",
+			Type:     "State Transfer Required",
+			Severity: "danger",
+			Message:  "State transfer required:\n\tGroup: f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1:30\n\tLocal: f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1:-1",
+			Fields: map[string]interface{}{
+				"group_uuid":  "f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1",
+				"group_seqno": float64(30),
+				"local_uuid":  "f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1",
+				"local_seqno": float64(-1),
+			},
+			Raw:    strings.Split(strings.TrimSuffix(log, "\n"), "\n")[1:],
+			Source: "mysql.err.log",
+			Line:   2,
+		},
+	}
+
+	var output bytes.Buffer
+	if err := (JSONRenderer{}).Render(&output, events.Stream(), nodes); err != nil {
+		t.Fatal(err)
+	}
+	var got []decodedEvent
+	if err := json.Unmarshal(output.Bytes(), &got); err != nil {
+		t.Fatalf("%s\n%s", err, output.String())
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("event %d:\ngot  %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+
+	// The same events, one object per line
+	output.Reset()
+	if err := (NDJSONRenderer{}).Render(&output, events.Stream(), nodes); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("ndjson: got %d lines, want %d:\n%s", len(lines), len(want), output.String())
+	}
+	for i, line := range lines {
+		var e decodedEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Errorf("ndjson line %d: %s", i+1, err)
+			continue
+		}
+		if !reflect.DeepEqual(e, got[i]) {
+			t.Errorf("ndjson line %d:\ngot  %+v\nwant %+v", i+1, e, got[i])
+		}
+	}
+}
+
+func TestJSONRendererEmpty(t *testing.T) {
+	tests := []struct {
+		renderer Renderer
+		want     string
+	}{
+		{JSONRenderer{}, "[]\n"},
+		{NDJSONRenderer{}, ""},
+	}
+
+	for _, test := range tests {
+		var output bytes.Buffer
+		if err := test.renderer.Render(&output, Timeline{}.Stream(), []Node{{Name: "mysql-0"}}); err != nil {
+			t.Fatal(err)
+		}
+		if output.String() != test.want {
+			t.Errorf("%T: got %q, want %q", test.renderer, output.String(), test.want)
+		}
+	}
+}
@@ -0,0 +1,846 @@
+package timeline
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeFormatDefault  = "2006-01-02 15:04:05"
+	timeFormatWsrepSst = "20060102 15:04:05"
+	timeFormatMysqld   = "060102 15:04:05"
+	timeFormatISO      = "2006-01-02T15:04:05Z07:00"  // MySQL 5.7+, fractional seconds are optional
+	timeFormatRow      = "2006-01-02 15:04:05.999999" // Trailing zeros are dropped so rows still sort as strings
+
+	isoTimeMatcher = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?(Z|[+-][0-9]{2}:[0-9]{2})`)
+
+	// Start of a new entry in a log, in any of the timestamp formats, or a
+	// line from the wsrep_sst scripts. The lines that follow the first of an
+	// event, e.g. the fields of Quorum results, don't have one.
+	entryStartMatcher = regexp.MustCompile(`^([0-9]{4}-[0-9]{2}-[0-9]{2}[T ]|[0-9]{6} +[0-9]{1,2}:[0-9]{2}:[0-9]{2}|WSREP_SST: )`)
+
+	// MySQL 5.7+ tags each line with an error code and subsystem,
+	// e.g. "[MY-000000] [Galera] Shifting ...", where MariaDB writes "WSREP: Shifting ..."
+	subsystemTagMatcher = regexp.MustCompile(`\[MY-[0-9]{6}\] \[([A-Za-z-]+)\] `)
+
+	// Timestamps of the wsrep_sst scripts and of mysqld before MySQL 5.7
+	wsrepSstTimeMatcher = regexp.MustCompile(`([0-9]{8} [0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?)`)
+	mysqldTimeMatcher   = regexp.MustCompile(`([0-9]{6} [0-9]{2}:[0-9]{2}:[0-9]{2})`)
+
+	// Fields of the events, compiled once rather than for every line
+	shiftMatcher             = regexp.MustCompile(` Shifting (.*) -> (.*) \(TO: (-?[0-9]*)\)`)
+	quorumComponentMatcher   = regexp.MustCompile(`component\s*= (.*),`)
+	quorumConfIDMatcher      = regexp.MustCompile(`conf_id\s*= (-?[0-9]+),`)
+	quorumMembersMatcher     = regexp.MustCompile(`members\s*= ([0-9]*)/([0-9]*) \(joined/total\),`)
+	quorumGroupUUIDMatcher   = regexp.MustCompile(`group UUID\s*= (.*)`)
+	recoveredPositionMatcher = regexp.MustCompile(`Recovered position:? (.*)`)
+	viewStatusMatcher        = regexp.MustCompile(`view\(view_id\(([A-Z_]*),`)
+	sstRoleMatcher           = regexp.MustCompile(`--role '(.*)' --address '(.*?)' --`)
+	wsrepXidMatcher          = regexp.MustCompile(`Set WSREPXid for InnoDB:  (.*)`)
+	slaveSQLErrorMatcher     = regexp.MustCompile(`Slave SQL: (Error.*)`)
+	fatalErrorMatcher        = regexp.MustCompile(` Fatal error: (.*)`)
+	innodbMessageMatcher     = regexp.MustCompile(` InnoDB: (.*)`)
+	istFailureMatcher        = regexp.MustCompile(`incremental state transfer: (.*)`)
+
+	// Give each state a numeric value so shifts
+	// to a lower state can be flagged
+	shiftState = map[string]int{
+		"ERROR":          10,
+		"DESTROYED":      20,
+		"CLOSED":         30,
+		"OPEN":           40,
+		"PRIMARY":        50,
+		"JOINER":         60,
+		"DONOR/DESYNCED": 70,
+		"DONOR":          75,
+		"JOINED":         80,
+		"SYNCED":         90,
+	}
+
+	// Event matchers for all know events
+	builtinMatchers = []EventMatcher{
+		EventMatcher{
+			"Node is changing state",
+			"WSREP: Shifting",
+			func(scanner *Scanner) (*Event, error) {
+				// 2015-10-28 16:36:52 10144 [Note] WSREP: Shifting PRIMARY -> JOINER (TO: 31389)
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				matches, err := findSubmatch(shiftMatcher, lines[0])
+				if err != nil {
+					return nil, err
+				}
+				seqno, err := strconv.ParseInt(matches[3], 10, 64)
+				if err != nil {
+					return nil, err
+				}
+
+				severity := SeveritySuccess
+				if shiftState[matches[1]] > shiftState[matches[2]] {
+					severity = SeverityDanger
+				}
+
+				fields := Fields{"from": matches[1], "to": matches[2], "seqno": seqno}
+
+				return NewEvent(eventTime, 0, severity, fields, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return fmt.Sprintf("Shifting: %s to %s", e.Fields["from"], f.Highlight(e.Fields["to"], e.Severity != SeverityDanger))
+			},
+		},
+		EventMatcher{
+			"Quorum results",
+			"WSREP: Quorum results:",
+			func(scanner *Scanner) (*Event, error) {
+				// 2015-10-28 14:28:50 553 [Note] WSREP: Quorum results:
+				//     version    = 3,
+				//     component  = PRIMARY,
+				//     conf_id    = 4,
+				//     members    = 3/3 (joined/total),
+				//     act_id     = 11152,
+				//     last_appl. = -1,
+				//     protocols  = 0/7/3 (gcs/repl/appl),
+				//     group UUID = 98ed75de-7c05-11e5-9743-de4abc22bd11
+				//
+				// Galera 4 adds "vote policy= 0," before the group UUID, so
+				// the fields are found by name rather than by line
+				lines, err := ScanUntil(scanner, "group UUID", 12)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				matches, err := findSubmatchIn(quorumComponentMatcher, lines[1:])
+				if err != nil {
+					return nil, err
+				}
+				component := matches[1]
+				matches, err = findSubmatchIn(quorumConfIDMatcher, lines[1:])
+				if err != nil {
+					return nil, err
+				}
+				confID, err := strconv.Atoi(matches[1])
+				if err != nil {
+					return nil, err
+				}
+				matches, err = findSubmatchIn(quorumMembersMatcher, lines[1:])
+				if err != nil {
+					return nil, err
+				}
+				membersJoined, err := strconv.Atoi(matches[1])
+				if err != nil {
+					return nil, err
+				}
+				membersTotal, err := strconv.Atoi(matches[2])
+				if err != nil {
+					return nil, err
+				}
+				matches, err = findSubmatchIn(quorumGroupUUIDMatcher, lines[1:])
+				if err != nil {
+					return nil, err
+				}
+				uuid := strings.TrimSpace(matches[1])
+
+				severity := SeveritySuccess
+				if component != "PRIMARY" || membersJoined != membersTotal {
+					severity = SeverityDanger
+				}
+
+				fields := Fields{
+					"component":      component,
+					"conf_id":        confID,
+					"members_joined": membersJoined,
+					"members_total":  membersTotal,
+					"uuid":           uuid,
+				}
+
+				return NewEvent(eventTime, 0, severity, fields, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				component := f.Highlight(e.Fields["component"], e.Fields["component"] == "PRIMARY")
+				members := f.Highlight(fmt.Sprintf("%d/%d", e.Fields["members_joined"], e.Fields["members_total"]), e.Fields["members_joined"] == e.Fields["members_total"])
+				return fmt.Sprintf("Quorum results: Component = %s, Members = %s", component, members)
+			},
+		},
+		EventMatcher{
+			"State Transfer Required",
+			"WSREP: State transfer required:",
+			func(scanner *Scanner) (*Event, error) {
+				// 2015-10-28 16:36:51 10144 [Note] WSREP: State transfer required:
+				//     Group state: 98ed75de-7c05-11e5-9743-de4abc22bd11:31382
+				//     Local state: 98ed75de-7c05-11e5-9743-de4abc22bd11:11152
+				lines, err := ScanLines(scanner, 3)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				groupState := strings.SplitN(lines[1], ":", 2)
+				localState := strings.SplitN(lines[2], ":", 2)
+				if len(groupState) != 2 || len(localState) != 2 {
+					return nil, fmt.Errorf("expected uuid:seqno states, got %q and %q", lines[1], lines[2])
+				}
+				groupUUID, groupSeqno, err := parsePosition(groupState[1])
+				if err != nil {
+					return nil, err
+				}
+				localUUID, localSeqno, err := parsePosition(localState[1])
+				if err != nil {
+					return nil, err
+				}
+
+				severity := SeveritySuccess
+				if localSeqno == -1 {
+					severity = SeverityDanger
+				}
+
+				fields := Fields{
+					"group_uuid":  groupUUID,
+					"group_seqno": groupSeqno,
+					"local_uuid":  localUUID,
+					"local_seqno": localSeqno,
+				}
+
+				return NewEvent(eventTime, 0, severity, fields, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				group := fmt.Sprintf("%s:%d", e.Fields["group_uuid"], e.Fields["group_seqno"])
+				local := f.Highlight(fmt.Sprintf("%s:%d", e.Fields["local_uuid"], e.Fields["local_seqno"]), e.Severity != SeverityDanger)
+				return fmt.Sprintf("State transfer required:\n\tGroup: %s\n\tLocal: %s", group, local)
+			},
+		},
+		EventMatcher{
+			"WSREP recovered position",
+			"WSREP: Recovered position",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-06-14 14:02:28 139993574066048 [Note] WSREP: Recovered position f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1:40847697
+				// 170614 14:02:28 mysqld_safe WSREP: Recovered position f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1:40847697
+				// 2021-03-01T10:00:00.000000Z 0 [System] [MY-000000] [WSREP] Recovered position: f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1:40847697
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeAny(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				matches, err := findSubmatch(recoveredPositionMatcher, lines[0])
+				if err != nil {
+					return nil, err
+				}
+				uuid, seqno, err := parsePosition(matches[1])
+				if err != nil {
+					return nil, err
+				}
+
+				severity := SeveritySuccess
+				if seqno == -1 {
+					severity = SeverityDanger
+				}
+
+				fields := Fields{"uuid": uuid, "seqno": seqno}
+
+				return NewEvent(eventTime, 0, severity, fields, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				recovered := fmt.Sprintf("%s:%d", e.Fields["uuid"], e.Fields["seqno"])
+				return fmt.Sprintf("Recovered position: %s", f.Highlight(recovered, e.Severity != SeverityDanger))
+			},
+		},
+		EventMatcher{
+			"Interruptor",
+			"SST disabled due to danger of data loss",
+			func(scanner *Scanner) (*Event, error) {
+				// WSREP_SST: [ERROR] ############################################################################## (20170506 15:14:06.901)
+				// WSREP_SST: [ERROR] SST disabled due to danger of data loss. Verify data and bootstrap the cluster (20170506 15:14:06.902)
+				// WSREP_SST: [ERROR] ############################################################################## (20170506 15:14:06.904)
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeWsrepSst(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				return NewEvent(eventTime, 0, SeverityDanger, Fields{}, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return f.Danger(`++++++++++ INTERRUPTOR ++++++++++`)
+			},
+		},
+		EventMatcher{
+			"MySQL ended",
+			" from pid file ",
+			func(scanner *Scanner) (*Event, error) {
+				// 170505 14:35:47 mysqld_safe mysqld from pid file /tmp/tmp-mysql.pid ended
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeMysqld(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				return NewEvent(eventTime, 0, SeverityDanger, Fields{}, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return f.Danger("PID ended")
+			},
+		},
+		EventMatcher{
+			"MySQL normal shutdown",
+			"mysqld: Normal shutdown",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-05-05 14:35:45 139716968405760 [Note] /var/vcap/packages/mariadb/bin/mysqld: Normal shutdown
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				return NewEvent(eventTime, 0, SeveritySuccess, Fields{}, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return f.Success("Normal Shutdown")
+			},
+		},
+		EventMatcher{
+			"MySQL startup",
+			"starting as process",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-05-06 16:53:13 140445682804608 [Note] /var/vcap/packages/mariadb/bin/mysqld (mysqld 10.1.18-MariaDB) starting as process 24588 ...
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				return NewEvent(eventTime, 0, SeverityInfo, Fields{}, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return "MySQL starting up"
+			},
+		},
+		EventMatcher{
+			"InnoDB shutdown",
+			"InnoDB: Starting shutdown...",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-05-06 16:53:08 140348661906176 [Note] InnoDB: Starting shutdown...
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				return NewEvent(eventTime, 0, SeverityInfo, Fields{}, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return "InnoDB shutting down"
+			},
+		},
+		EventMatcher{
+			"InnoDB shutdown complete",
+			"mysqld: Shutdown complete",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-05-05 14:35:47 139716968405760 [Note] /var/vcap/packages/mariadb/bin/mysqld: Shutdown complete
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				return NewEvent(eventTime, 0, SeverityInfo, Fields{}, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return "MySQL shutdown complete"
+			},
+		},
+		EventMatcher{
+			"Primary not possible",
+			"WSREP: no nodes coming from prim view",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-05-05  6:50:37 140137601001344 [Warning] WSREP: no nodes coming from prim view, prim not possible
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				return NewEvent(eventTime, 0, SeverityWarning, Fields{}, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return "Primary not possible"
+			},
+		},
+		EventMatcher{
+			"Cluster View",
+			"WSREP: view(",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-06-14 10:11:35 139887269365504 [Note] WSREP: view(view_id(NON_PRIM,55433460,408) memb {
+				//     55433460,0
+				// } joined {
+				// } left {
+				// } partitioned {
+				//     8a7f3cd1,0
+				// })
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				view := &clusterView{}
+				if strings.Contains(lines[0], "empty") {
+					view.Status = "empty"
+				} else if strings.Contains(lines[0], "view_id") {
+					matches, err := findSubmatch(viewStatusMatcher, lines[0])
+					if err != nil {
+						return nil, err
+					}
+					view.Status = matches[1]
+
+					lines, err = ScanUntil(scanner, "})", maxViewLines)
+					if err != nil {
+						return nil, err
+					}
+					view.parseMembers(lines[1:])
+				}
+
+				return NewEvent(eventTime, 0, view.severity(), view.fields(), lines), nil
+			},
+			formatView,
+		},
+		EventMatcher{
+			"xtrabackup",
+			"WSREP: Running: ",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-06-14 19:10:58 140682204215040 [Note] WSREP: Running: 'wsrep_sst_xtrabackup-v2 --role 'joiner' --address '10.19.148.90' --datadir '/var/vcap/store/mysql/'   --parent '32691' --binlog 'mysql-bin' '
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				matches, err := findSubmatch(sstRoleMatcher, lines[0])
+				if err != nil {
+					return nil, err
+				}
+				role := matches[1]
+				// The donor is given the joiner's address with the port and path to send to
+				address := strings.SplitN(matches[2], ":", 2)[0]
+
+				if role != "joiner" && role != "donor" {
+					return nil, fmt.Errorf("unknown SST role %q", role)
+				}
+
+				fields := Fields{"role": role, "address": address}
+
+				return NewEvent(eventTime, 0, SeverityInfo, fields, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				node := f.NodeByAddress(e.Fields["address"].(string))
+				if e.Fields["role"] == "joiner" {
+					return fmt.Sprintf("Node %s joining via SST", node)
+				}
+				return fmt.Sprintf("Donating to node %s via SST", node)
+			},
+		},
+		EventMatcher{
+			"WSREP Transaction ID",
+			"WSREP: Set WSREPXid for InnoDB: ",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-06-22 16:50:12 140484737350400 [Note] WSREP: Set WSREPXid for InnoDB:  13f831b9-2d93-11e6-9385-a607db88d15b:36559417
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				matches, err := findSubmatch(wsrepXidMatcher, lines[0])
+				if err != nil {
+					return nil, err
+				}
+				uuid, seqno, err := parsePosition(matches[1])
+				if err != nil {
+					return nil, err
+				}
+
+				fields := Fields{"uuid": uuid, "seqno": seqno}
+
+				return NewEvent(eventTime, 0, SeverityInfo, fields, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return fmt.Sprintf("WSREPXid = %s:%d", e.Fields["uuid"], e.Fields["seqno"])
+			},
+		},
+		EventMatcher{
+			"Node consistency compromized",
+			"WSREP: Node consistency compromized",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-06-14  8:01:24 140433225386752 [ERROR] WSREP: Node consistency compromized, aborting...
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				return NewEvent(eventTime, 0, SeverityDanger, Fields{}, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return f.Danger("Node consistency compromized")
+			},
+		},
+		EventMatcher{
+			"Slave SQL Error",
+			" Slave SQL: Error",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-03-24 10:25:00 140656657582848 [ERROR] Slave SQL: Error 'Table 'cf_f08ec188_bbf7_4a27_a001_97749f736849.COL1' doesn't exist' on query. Default database: 'cf_f08ec188_bbf7_4a27_a001_97749f736849'. Query: 'alter table COL1 drop foreign key FK8kw677hwx7cgwi4g1r6c56398', Internal MariaDB error code: 1146
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				matches, err := findSubmatch(slaveSQLErrorMatcher, lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				fields := Fields{"error": matches[1]}
+
+				return NewEvent(eventTime, 0, SeverityDanger, fields, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return f.Danger("Slave SQL Error")
+			},
+		},
+		EventMatcher{
+			"Fatal Error",
+			" Fatal error:",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-05-06 14:51:43 139983057127296 [ERROR] Fatal error: Can't open and lock privilege tables: Table 'mysql.user' doesn't exist
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				matches, err := findSubmatch(fatalErrorMatcher, lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				fields := Fields{"error": matches[1]}
+
+				return NewEvent(eventTime, 0, SeverityDanger, fields, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return f.Danger(fmt.Sprintf("Fatal Error: %s", e.Fields["error"]))
+			},
+		},
+		EventMatcher{
+			"Assertion Failure",
+			"InnoDB: Assertion failure",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-06-22 15:51:49 7f99b39b7700  InnoDB: Assertion failure in thread 140298120034048 in file pars
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				// MariaDB follows with the failing assertion, MySQL 8 logs it
+				// as an entry of its own
+				if next, ok := scanner.peek(); ok && !entryStartMatcher.MatchString(next) && scanner.Scan() {
+					lines = append(lines, scanner.Text())
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				matches, err := findSubmatch(innodbMessageMatcher, lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				fields := Fields{"error": matches[1]}
+
+				return NewEvent(eventTime, 0, SeverityDanger, fields, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return fmt.Sprintf("InnoDB: %s", f.Danger(e.Fields["error"].(string)))
+			},
+		},
+		EventMatcher{
+			"Bootstrap",
+			"WSREP: 'wsrep-new-cluster' option used",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-06-14 14:21:49 140348199405440 [Note] WSREP: 'wsrep-new-cluster' option used, bootstrapping the cluster
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				return NewEvent(eventTime, 0, SeverityDanger, Fields{}, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return f.Danger("++++++++++ BOOTSTRAPPING ++++++++++")
+			},
+		},
+		EventMatcher{
+			"Failed IST",
+			"WSREP: Failed to prepare for incremental state transfer",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-05-06 15:15:24 140137773021952 [Warning] WSREP: Failed to prepare for incremental state transfer: Local state UUID (00000000-0000-0000-0000-000000000000) does not match group state UUID (f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1): 1 (Operation not permitted)
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				matches, err := findSubmatch(istFailureMatcher, lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				fields := Fields{"error": matches[1]}
+
+				return NewEvent(eventTime, 0, SeverityDanger, fields, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return f.Danger("Failed to prepare for IST")
+			},
+		},
+		EventMatcher{
+			"IST Received",
+			"WSREP: IST received:",
+			func(scanner *Scanner) (*Event, error) {
+				// 2017-05-06 15:15:24 140137773021952 [Warning] WSREP: Failed to prepare for incremental state transfer: Local state UUID (00000000-0000-0000-0000-000000000000) does not match group state UUID (f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1): 1 (Operation not permitted)
+				lines, err := ScanLines(scanner, 1)
+				if err != nil {
+					return nil, err
+				}
+				eventTime, err := GetTimeDefault(lines[0])
+				if err != nil {
+					return nil, err
+				}
+
+				return NewEvent(eventTime, 0, SeveritySuccess, Fields{}, lines), nil
+			},
+			func(e *Event, f *MessageFormatter) string {
+				return f.Success("IST Received")
+			},
+		},
+	}
+)
+
+func GetTimeDefault(line string) (time.Time, error) {
+	// "2006-01-02 15:04:05"
+	if isoTimeMatcher.MatchString(line) {
+		return GetTimeISO(line)
+	}
+
+	if len(line) < len(timeFormatDefault) {
+		return time.Time{}, fmt.Errorf("no timestamp in %q", line)
+	}
+
+	return time.Parse(timeFormatDefault, line[:len(timeFormatDefault)])
+}
+
+func GetTimeWsrepSst(line string) (time.Time, error) {
+	// "20060102 15:04:05.000"
+	if isoTimeMatcher.MatchString(line) {
+		return GetTimeISO(line)
+	}
+
+	matches, err := findSubmatch(wsrepSstTimeMatcher, line)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(timeFormatWsrepSst, matches[1])
+}
+
+func GetTimeMysqld(line string) (time.Time, error) {
+	// "060102 15:04:05"
+	if isoTimeMatcher.MatchString(line) {
+		return GetTimeISO(line)
+	}
+
+	matches, err := findSubmatch(mysqldTimeMatcher, line)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(timeFormatMysqld, matches[1])
+}
+
+func GetTimeISO(line string) (time.Time, error) {
+	// "2006-01-02T15:04:05.000000Z" or "2006-01-02T15:04:05.000000+01:00"
+	t, err := time.Parse(timeFormatISO, isoTimeMatcher.FindString(line))
+
+	// Keep all nodes on the same clock so they can be compared
+	return t.UTC(), err
+}
+
+// GetTimeAny tries every known timestamp format, for lines where the
+// format is not known in advance. The wsrep_sst format is tried before the
+// mysqld one, which would match its last 6 digits and drop the fraction.
+func GetTimeAny(line string) (time.Time, error) {
+	var err error
+	for _, getTime := range []func(string) (time.Time, error){GetTimeDefault, GetTimeWsrepSst, GetTimeMysqld} {
+		var t time.Time
+		if t, err = getTime(line); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parsePosition splits a Galera position, "uuid:seqno"
+func parsePosition(position string) (string, int64, error) {
+	position = strings.TrimSpace(position)
+	i := strings.LastIndex(position, ":")
+	if i < 0 {
+		return "", 0, fmt.Errorf("expected uuid:seqno, got %q", position)
+	}
+
+	seqno, err := strconv.ParseInt(position[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return position[:i], seqno, nil
+}
+
+// findSubmatch is regexp.FindStringSubmatch but returns an error when the
+// line does not match, rather than a nil slice that would be indexed
+func findSubmatch(matcher *regexp.Regexp, line string) ([]string, error) {
+	matches := matcher.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, fmt.Errorf("%q does not match `%s`", line, matcher)
+	}
+	return matches, nil
+}
+
+// findSubmatchIn is findSubmatch on the first of the lines that matches, for
+// events whose lines differ between versions
+func findSubmatchIn(matcher *regexp.Regexp, lines []string) ([]string, error) {
+	for _, line := range lines {
+		if matches := matcher.FindStringSubmatch(line); matches != nil {
+			return matches, nil
+		}
+	}
+	return nil, fmt.Errorf("no line matches `%s`", matcher)
+}
+
+// normalizeLine rewrites MySQL 5.7+ subsystem tags to the MariaDB style prefix
+// so a single Signature matches logs from either server
+func normalizeLine(line string) string {
+	return subsystemTagMatcher.ReplaceAllStringFunc(line, func(tag string) string {
+		subsystem := subsystemTagMatcher.FindStringSubmatch(tag)[1]
+		switch subsystem {
+		case "Galera", "WSREP":
+			return "WSREP: "
+		case "Server":
+			return ""
+		}
+		return subsystem + ": "
+	})
+}
+
+// ScanLines reads the count lines of an event. Like ScanUntil, an event cut
+// short ends before the next entry in the log.
+func ScanLines(scanner *Scanner, count int) ([]string, error) {
+	lines := []string{scanner.Text()}
+	for len(lines) < count {
+		if next, ok := scanner.peek(); ok && entryStartMatcher.MatchString(next) {
+			return lines, fmt.Errorf("expected %d lines but the next entry started after %d", count, len(lines))
+		}
+		if !scanner.Scan() {
+			return lines, fmt.Errorf("expected %d lines but the log ended after %d", count, len(lines))
+		}
+		lines = append(lines, scanner.Text())
+	}
+	return lines, nil
+}
+
+// ScanUntil reads the lines of an event up to and including the line
+// containing terminator, giving up after max lines. An event cut short, e.g.
+// by a crash, also ends before the next entry in the log, which is left for
+// the matchers.
+func ScanUntil(scanner *Scanner, terminator string, max int) ([]string, error) {
+	lines := []string{scanner.Text()}
+	for !strings.Contains(lines[len(lines)-1], terminator) {
+		if len(lines) == max {
+			return lines, fmt.Errorf("%q not found within %d lines", terminator, max)
+		}
+		if next, ok := scanner.peek(); ok && entryStartMatcher.MatchString(next) {
+			return lines, fmt.Errorf("expected %q but the next entry started after %d lines", terminator, len(lines))
+		}
+		if !scanner.Scan() {
+			return lines, fmt.Errorf("expected %q but the log ended after %d lines", terminator, len(lines))
+		}
+		lines = append(lines, scanner.Text())
+	}
+	return lines, nil
+}
@@ -0,0 +1,437 @@
+package timeline
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetTimeDefault(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"2017-06-14 19:10:57 140 [Note] WSREP: Shifting", "2017-06-14T19:10:57Z"},
+		{"2017-06-14T19:10:57Z 0 [Note] [MY-000000] [Galera] Shifting", "2017-06-14T19:10:57Z"},
+		{"2017-06-14T19:10:57.120000Z 0 [Note] [MY-000000] [Galera] Shifting", "2017-06-14T19:10:57.12Z"},
+		{"2017-06-14T20:10:57.5+01:00 0 [Note] [MY-000000] [Galera] Shifting", "2017-06-14T19:10:57.5Z"},
+		{"2017-06-14T14:10:57.000001-05:00 0 [Note] [MY-000000] [Galera] Shifting", "2017-06-14T19:10:57.000001Z"},
+	}
+
+	for _, test := range tests {
+		got, err := GetTimeDefault(test.line)
+		if err != nil {
+			t.Errorf("%q: %s", test.line, err)
+			continue
+		}
+		if got.Location() != time.UTC || got.Format(time.RFC3339Nano) != test.want {
+			t.Errorf("%q: got %s, want %s", test.line, got.Format(time.RFC3339Nano), test.want)
+		}
+	}
+
+	if _, err := GetTimeDefault("WSREP_SST: [INFO] no timestamp"); err == nil {
+		t.Error("expected an error for a line without a timestamp")
+	}
+}
+
+func TestGetTimeAny(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"2017-06-14 19:10:57 140 [Note] mysqld: Normal shutdown", "2017-06-14T19:10:57Z"},
+		{"2017-06-14T19:10:57.25Z 0 [System] [MY-010116] [Server] starting", "2017-06-14T19:10:57.25Z"},
+		{"170614 19:10:57 mysqld_safe mysqld from pid file /tmp/mysql.pid ended", "2017-06-14T19:10:57Z"},
+		{"WSREP_SST: [ERROR] SST disabled (20170614 19:10:57.902)", "2017-06-14T19:10:57.902Z"},
+	}
+
+	for _, test := range tests {
+		got, err := GetTimeAny(test.line)
+		if err != nil {
+			t.Errorf("%q: %s", test.line, err)
+			continue
+		}
+		if got.Format(time.RFC3339Nano) != test.want {
+			t.Errorf("%q: got %s, want %s", test.line, got.Format(time.RFC3339Nano), test.want)
+		}
+	}
+}
+
+func TestNormalizeLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{
+			"2017-06-14T19:10:57Z 0 [Note] [MY-000000] [Galera] Shifting SYNCED -> OPEN (TO: 5)",
+			"2017-06-14T19:10:57Z 0 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 5)",
+		},
+		{
+			"2017-06-14T19:10:57Z 0 [Note] [MY-000000] [WSREP] Recovered position",
+			"2017-06-14T19:10:57Z 0 [Note] WSREP: Recovered position",
+		},
+		{
+			"2017-06-14T19:10:57Z 0 [System] [MY-010116] [Server] /usr/sbin/mysqld starting as process 1",
+			"2017-06-14T19:10:57Z 0 [System] /usr/sbin/mysqld starting as process 1",
+		},
+		{
+			"2017-06-14T19:10:57Z 0 [ERROR] [MY-012345] [InnoDB] Assertion failure",
+			"2017-06-14T19:10:57Z 0 [ERROR] InnoDB: Assertion failure",
+		},
+		{
+			"2017-06-14 19:10:57 140 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 5)",
+			"2017-06-14 19:10:57 140 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 5)",
+		},
+	}
+
+	for _, test := range tests {
+		if got := normalizeLine(test.line); got != test.want {
+			t.Errorf("got  %q\nwant %q", got, test.want)
+		}
+	}
+}
+
+func TestQuorumResults(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+	}{
+		{
+			"Galera 3",
+			`2017-06-14 10:00:00 1 [Note] WSREP: Quorum results:
+	version    = 4,
+	component  = PRIMARY,
+	conf_id    = 2,
+	members    = 2/3 (joined/total),
+	act_id     = 12,
+	last_appl. = 0,
+	protocols  = 0/7/3 (gcs/repl/appl),
+	group UUID = f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1
+`,
+		},
+		{
+			"Galera 4",
+			`2017-06-14T10:00:00.000000Z 0 [Note] [MY-000000] [Galera] Quorum results:
+	version    = 6,
+	component  = PRIMARY,
+	conf_id    = 2,
+	members    = 2/3 (joined/total),
+	act_id     = 12,
+	last_appl. = 0,
+	protocols  = 2/10/4 (gcs/repl/appl),
+	vote policy= 0,
+	group UUID = f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events, warnings := NewParser().ParseReader(0, strings.NewReader(test.log))
+			if len(warnings) > 0 || len(events) != 1 {
+				t.Fatalf("got %d events and warnings %v, want 1 event", len(events), warnings)
+			}
+
+			want := Fields{
+				"component":      "PRIMARY",
+				"conf_id":        2,
+				"members_joined": 2,
+				"members_total":  3,
+				"uuid":           "f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1",
+			}
+			for name, value := range want {
+				if events[0].Fields[name] != value {
+					t.Errorf("%s: got %v, want %v", name, events[0].Fields[name], value)
+				}
+			}
+			if events[0].Severity != SeverityDanger {
+				t.Errorf("severity: got %s, want danger as a member has not joined", events[0].Severity)
+			}
+		})
+	}
+}
+
+func TestRecoveredPosition(t *testing.T) {
+	uuid := "f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1"
+	tests := []struct {
+		name  string
+		line  string
+		time  string
+		seqno int64
+	}{
+		{"MariaDB", "2017-06-14 14:02:28 139993574066048 [Note] WSREP: Recovered position " + uuid + ":40847697", "2017-06-14T14:02:28Z", 40847697},
+		{"mysqld_safe", "170614 14:02:28 mysqld_safe WSREP: Recovered position " + uuid + ":40847697", "2017-06-14T14:02:28Z", 40847697},
+		{"MariaDB 10.4", "2021-03-01 10:00:00 0 [Note] WSREP: Recovered position: " + uuid + ":12", "2021-03-01T10:00:00Z", 12},
+		{"Galera 4", "2021-03-01T10:00:00.000000Z 0 [System] [MY-000000] [WSREP] Recovered position: " + uuid + ":12", "2021-03-01T10:00:00Z", 12},
+		{"not recovered", "2017-06-14 14:02:28 139993574066048 [Note] WSREP: Recovered position " + uuid + ":-1", "2017-06-14T14:02:28Z", -1},
+	}
+
+	for _, test := range tests {
+		events, warnings := NewParser().ParseReader(0, strings.NewReader(test.line+"\n"))
+		if len(warnings) > 0 || len(events) != 1 {
+			t.Errorf("%s: got %d events and warnings %v, want 1 event", test.name, len(events), warnings)
+			continue
+		}
+		e := events[0]
+		if e.Type != "WSREP recovered position" || e.Datetime.Format(time.RFC3339Nano) != test.time {
+			t.Errorf("%s: got %s at %s, want the recovered position at %s", test.name, e.Type, e.Datetime.Format(time.RFC3339Nano), test.time)
+		}
+		if e.Fields["uuid"] != uuid || e.Fields["seqno"] != test.seqno {
+			t.Errorf("%s: got %v:%v, want %s:%d", test.name, e.Fields["uuid"], e.Fields["seqno"], uuid, test.seqno)
+		}
+	}
+}
+
+func TestEventEndsAtNextEntry(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+	}{
+		{
+			"Quorum results",
+			`2017-06-14 10:00:00 1 [Note] WSREP: Quorum results:
+	version    = 4,
+	component  = PRIMARY,
+`,
+		},
+		{
+			"Cluster View",
+			`2017-06-14T10:00:00.000000Z 0 [Note] [MY-000000] [Galera] view(view_id(PRIM,3e2ba4a8,3) memb {
+	3e2ba4a8,0
+`,
+		},
+		{
+			"State Transfer Required",
+			"2017-06-14 10:00:00 1 [Note] WSREP: State transfer required:\n",
+		},
+	}
+	shifts := `2017-06-14 10:00:01 1 [Note] WSREP: Shifting PRIMARY -> JOINER (TO: 30)
+2017-06-14 10:00:05 1 [Note] WSREP: Shifting JOINER -> JOINED (TO: 30)
+2017-06-14 10:00:06 1 [Note] WSREP: Shifting JOINED -> SYNCED (TO: 31)
+`
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events, warnings := NewParser().ParseReader(0, strings.NewReader(test.log+shifts))
+			if len(warnings) != 1 || len(events) != 4 {
+				t.Fatalf("got %d events and warnings %v, want the unparsed block and 3 shifts", len(events), warnings)
+			}
+
+			if _, ok := events[0].Fields["parse_error"]; !ok || events[0].Raw != strings.TrimSuffix(test.log, "\n") {
+				t.Errorf("got %q, want the block unparsed up to the next entry", events[0].Raw)
+			}
+			for i, event := range events[1:] {
+				if event.Type != "Node is changing state" || event.Line != warnings[0].Line+strings.Count(test.log, "\n")+i {
+					t.Errorf("event %d: got %s on line %d, want the shift on the line after", i+1, event.Type, event.Line)
+				}
+			}
+		})
+	}
+}
+
+func TestAssertionFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		raw  int
+	}{
+		{
+			"MariaDB",
+			"2017-06-22 15:51:49 7f99b39b7700  InnoDB: Assertion failure in thread 140298120034048 in file pars0pars.cc line 822\n" +
+				"InnoDB: Failing assertion: sym_node->table != NULL\n",
+			2,
+		},
+		{
+			"MySQL 8",
+			"2021-03-01T10:00:00.000000Z 0 [ERROR] [MY-013183] [InnoDB] Assertion failure: ibuf0ibuf.cc:3842 thread 140298120034048\n" +
+				"2021-03-01T10:00:00.000000Z 0 [ERROR] [MY-013183] [InnoDB] We intentionally generate a memory trap.\n",
+			1,
+		},
+		{"end of the log", "2017-06-22 15:51:49 7f99b39b7700  InnoDB: Assertion failure in thread 140298120034048 in file pars0pars.cc line 822\n", 1},
+	}
+
+	for _, test := range tests {
+		events, warnings := NewParser().ParseReader(0, strings.NewReader(test.log))
+		if len(warnings) > 0 || len(events) != 1 || events[0].Type != "Assertion Failure" {
+			t.Errorf("%s: got %d events and warnings %v, want the assertion failure", test.name, len(events), warnings)
+			continue
+		}
+		if got := strings.Count(events[0].Raw, "\n") + 1; got != test.raw {
+			t.Errorf("%s: got %d lines, want %d", test.name, got, test.raw)
+		}
+	}
+}
+
+func TestBuiltinMatchersMySQL8(t *testing.T) {
+	const uuid = "f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1"
+
+	tests := []struct {
+		log     string
+		want    string
+		fields  Fields
+		message string
+	}{
+		{
+			"2021-03-01T10:00:00.123456Z 0 [Note] [MY-000000] [Galera] Shifting SYNCED -> DONOR/DESYNCED (TO: 12)\n",
+			"Node is changing state",
+			Fields{"from": "SYNCED", "to": "DONOR/DESYNCED", "seqno": int64(12)},
+			"Shifting: SYNCED to DONOR/DESYNCED",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 0 [Note] [MY-000000] [Galera] Quorum results:\n\tversion    = 6,\n\tcomponent  = PRIMARY,\n\tconf_id    = 2,\n\tmembers    = 3/3 (joined/total),\n\tact_id     = 12,\n\tlast_appl. = 0,\n\tprotocols  = 2/10/4 (gcs/repl/appl),\n\tvote policy= 0,\n\tgroup UUID = " + uuid + "\n",
+			"Quorum results",
+			Fields{"component": "PRIMARY", "members_joined": 3, "members_total": 3},
+			"Quorum results: Component = PRIMARY, Members = 3/3",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 2 [Note] [MY-000000] [Galera] State transfer required:\n\tGroup state: " + uuid + ":31382\n\tLocal state: " + uuid + ":11152\n",
+			"State Transfer Required",
+			Fields{"group_seqno": int64(31382), "local_seqno": int64(11152)},
+			"State transfer required:\n\tGroup: " + uuid + ":31382\n\tLocal: " + uuid + ":11152",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 0 [System] [MY-000000] [WSREP] Recovered position: " + uuid + ":40847697\n",
+			"WSREP recovered position",
+			Fields{"uuid": uuid, "seqno": int64(40847697)},
+			"Recovered position: " + uuid + ":40847697",
+		},
+		{
+			"2021-03-01T10:00:00.902000Z 0 [ERROR] [MY-000000] [WSREP-SST] SST disabled due to danger of data loss. Verify data and bootstrap the cluster\n",
+			"Interruptor",
+			Fields{},
+			"++++++++++ INTERRUPTOR ++++++++++",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z mysqld_safe mysqld from pid file /var/lib/mysql/mysqld.pid ended\n",
+			"MySQL ended",
+			Fields{},
+			"PID ended",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 0 [Note] /usr/sbin/mysqld: Normal shutdown\n",
+			"MySQL normal shutdown",
+			Fields{},
+			"Normal Shutdown",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 0 [System] [MY-010116] [Server] /usr/sbin/mysqld (mysqld 8.0.22-13.1) starting as process 1234\n",
+			"MySQL startup",
+			Fields{},
+			"MySQL starting up",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 0 [Note] [MY-013072] [InnoDB] Starting shutdown...\n",
+			"InnoDB shutdown",
+			Fields{},
+			"InnoDB shutting down",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 0 [System] [MY-010910] [Server] /usr/sbin/mysqld: Shutdown complete (mysqld 8.0.22-13.1)  Percona XtraDB Cluster (GPL), Release rel13, Revision a48e6d5, WSREP version 26.4.3.\n",
+			"InnoDB shutdown complete",
+			Fields{},
+			"MySQL shutdown complete",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 0 [Warning] [MY-000000] [Galera] no nodes coming from prim view, prim not possible\n",
+			"Primary not possible",
+			Fields{},
+			"Primary not possible",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 0 [Note] [MY-000000] [Galera] view(view_id(NON_PRIM,0e5b7dee-8b4d,3) memb {\n\t0e5b7dee-8b4d,0\n} joined {\n} left {\n} partitioned {\n\t5a1c2b3d-9f10,0\n})\n",
+			"Cluster View",
+			Fields{"status": "NON_PRIM"},
+			"Cluster view: NON_PRIM (1 member; partitioned: 5a1c2b3d)",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 2 [Note] [MY-000000] [WSREP] Running: 'wsrep_sst_xtrabackup-v2 --role 'donor' --address '10.0.16.45:4444/xtrabackup_sst//1' --socket '/var/lib/mysql/mysql.sock' '\n",
+			"xtrabackup",
+			Fields{"role": "donor", "address": "10.0.16.45"},
+			"Donating to node 10.0.16.45 via SST",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 0 [Note] [MY-000000] [WSREP] Set WSREPXid for InnoDB:  " + uuid + ":36559417\n",
+			"WSREP Transaction ID",
+			Fields{"uuid": uuid, "seqno": int64(36559417)},
+			"WSREPXid = " + uuid + ":36559417",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 11 [ERROR] [MY-000000] [WSREP] Node consistency compromized, aborting...\n",
+			"Node consistency compromized",
+			Fields{},
+			"Node consistency compromized",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 11 [ERROR] [MY-010584] [Repl] Slave SQL: Error 'Duplicate entry '1' for key 'PRIMARY'' on query. Default database: 'test'. Query: 'insert into t values (1)', Error_code: MY-001062\n",
+			"Slave SQL Error",
+			Fields{"error": "Error 'Duplicate entry '1' for key 'PRIMARY'' on query. Default database: 'test'. Query: 'insert into t values (1)', Error_code: MY-001062"},
+			"Slave SQL Error",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 0 [ERROR] [MY-010119] [Server] Fatal error: Can't open and lock privilege tables: Table 'mysql.user' doesn't exist\n",
+			"Fatal Error",
+			Fields{"error": "Can't open and lock privilege tables: Table 'mysql.user' doesn't exist"},
+			"Fatal Error: Can't open and lock privilege tables: Table 'mysql.user' doesn't exist",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 0 [ERROR] [MY-013183] [InnoDB] Assertion failure: ibuf0ibuf.cc:3842:ibuf_btr_pcur_commit_specify_mtr thread 140298120034048\n2021-03-01T10:00:00.000000Z 0 [ERROR] [MY-013183] [InnoDB] We intentionally generate a memory trap.\n",
+			"Assertion Failure",
+			Fields{"error": "Assertion failure: ibuf0ibuf.cc:3842:ibuf_btr_pcur_commit_specify_mtr thread 140298120034048"},
+			"InnoDB: Assertion failure: ibuf0ibuf.cc:3842:ibuf_btr_pcur_commit_specify_mtr thread 140298120034048",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 0 [Note] [MY-000000] [WSREP] 'wsrep-new-cluster' option used, bootstrapping the cluster\n",
+			"Bootstrap",
+			Fields{},
+			"++++++++++ BOOTSTRAPPING ++++++++++",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 2 [Warning] [MY-000000] [Galera] Failed to prepare for incremental state transfer: Local state UUID (00000000-0000-0000-0000-000000000000) does not match group state UUID (" + uuid + "): 1 (Operation not permitted)\n",
+			"Failed IST",
+			Fields{"error": "Local state UUID (00000000-0000-0000-0000-000000000000) does not match group state UUID (" + uuid + "): 1 (Operation not permitted)"},
+			"Failed to prepare for IST",
+		},
+		{
+			"2021-03-01T10:00:00.000000Z 2 [Note] [MY-000000] [Galera] IST received: " + uuid + ":12\n",
+			"IST Received",
+			Fields{},
+			"IST Received",
+		},
+	}
+
+	tested := make(map[string]bool)
+	for _, test := range tests {
+		tested[test.want] = true
+
+		events, warnings := NewParser().ParseReader(0, strings.NewReader(test.log))
+		if len(warnings) > 0 || len(events) != 1 {
+			t.Errorf("%s: got %d events and warnings %v, want 1 event", test.want, len(events), warnings)
+			continue
+		}
+
+		event := events[0]
+		if event.Type != test.want {
+			t.Errorf("%s: got a %s event", test.want, event.Type)
+		}
+		if want := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC); event.Datetime.Truncate(time.Second) != want {
+			t.Errorf("%s: time: got %s, want %s", test.want, event.Datetime, want)
+		}
+		for name, value := range test.fields {
+			if event.Fields[name] != value {
+				t.Errorf("%s: %s: got %v, want %v", test.want, name, event.Fields[name], value)
+			}
+		}
+		if got := NewPlainFormatter(nil).Message(event); got != test.message {
+			t.Errorf("%s: got message %q, want %q", test.want, got, test.message)
+		}
+		if !strings.HasPrefix(test.log, event.Raw) {
+			t.Errorf("%s: raw lines are not as logged: %q", test.want, event.Raw)
+		}
+	}
+
+	for _, eventMatcher := range builtinMatchers {
+		if !tested[eventMatcher.Description] {
+			t.Errorf("no MySQL 8 line for %s", eventMatcher.Description)
+		}
+	}
+}
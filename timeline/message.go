@@ -0,0 +1,127 @@
+package timeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity is how much attention an event needs
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeveritySuccess
+	SeverityWarning
+	SeverityDanger
+)
+
+var severityNames = []string{"info", "success", "warning", "danger"}
+
+// ParseSeverity is the Severity with the name, e.g. "danger"
+func ParseSeverity(name string) (Severity, error) {
+	for i, severityName := range severityNames {
+		if name == severityName {
+			return Severity(i), nil
+		}
+	}
+	return SeverityInfo, fmt.Errorf("unknown severity %q, expected one of %s", name, strings.Join(severityNames, ", "))
+}
+
+func (s Severity) String() string {
+	if int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MarshalText writes the severity by name in JSON
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Fields are the values parsed from the log lines of an event, e.g. the
+// from and to states of a shift. Numbers are ints, seqnos are int64.
+type Fields map[string]interface{}
+
+// MessageFormatter builds the user friendly message for an event from its
+// fields, marking up the parts that need attention for the output format
+//   - Functions to mark up dangerous and successful parts
+//   - Nodes, to name the nodes that events refer to
+type MessageFormatter struct {
+	danger  func(string) string
+	success func(string) string
+	nodes   []Node
+	uuids   map[string]string
+}
+
+func NewMessageFormatter(danger, success func(string) string, nodes []Node) *MessageFormatter {
+	return &MessageFormatter{danger, success, nodes, nodeNamesByUUID(nodes)}
+}
+
+// NewPlainFormatter builds messages without any mark up
+func NewPlainFormatter(nodes []Node) *MessageFormatter {
+	plain := func(s string) string { return s }
+	return NewMessageFormatter(plain, plain, nodes)
+}
+
+func (f *MessageFormatter) Danger(s string) string {
+	return f.danger(s)
+}
+
+func (f *MessageFormatter) Success(s string) string {
+	return f.success(s)
+}
+
+// Highlight marks up v as successful if ok, otherwise as dangerous
+func (f *MessageFormatter) Highlight(v interface{}, ok bool) string {
+	if ok {
+		return f.Success(fmt.Sprint(v))
+	}
+	return f.Danger(fmt.Sprint(v))
+}
+
+// Mark marks up s for the severity, warnings as dangerous
+func (f *MessageFormatter) Mark(severity Severity, s string) string {
+	switch severity {
+	case SeveritySuccess:
+		return f.Success(s)
+	case SeverityWarning, SeverityDanger:
+		return f.Danger(s)
+	}
+	return s
+}
+
+// NodeByAddress labels the node with the address, if it is one of ours
+func (f *MessageFormatter) NodeByAddress(address string) string {
+	if i := nodeByAddress(f.nodes, address); i >= 0 {
+		return f.nodes[i].Label()
+	}
+	return address
+}
+
+// NodeByUUID labels the node with the (short) Galera UUID, if it is one of ours
+func (f *MessageFormatter) NodeByUUID(uuid string) string {
+	if name, ok := f.uuids[uuid]; ok {
+		return name
+	}
+	return uuid
+}
+
+// Message is the user friendly description of the event
+func (f *MessageFormatter) Message(e *Event) string {
+	if _, ok := e.Fields["parse_error"]; ok {
+		return f.Danger(fmt.Sprintf("unparsed %s", e.Type))
+	}
+
+	message := strings.TrimSpace(e.Raw)
+	if e.format != nil {
+		message = e.format(e, f)
+	}
+
+	// Flagged by a StateTracker
+	if problem, ok := e.Fields["problem"]; ok {
+		message += " " + f.Danger(fmt.Sprintf("(%s)", problem))
+	}
+
+	return message
+}
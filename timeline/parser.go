@@ -0,0 +1,366 @@
+package timeline
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// How far out of order events in a node's logs can be and still be sorted
+// by NodeStream
+const reorderWindow = 1000
+
+// Parser finds events in MySQL logs
+//   - Matchers to look for, the built in ones first
+//   - Lines of context to keep before and after each event
+//   - Length to truncate the log lines and text fields of each event to, 0 to keep them whole
+//   - Where to report the files being parsed, if anywhere
+//   - Part of the timeline to keep, events outside it are dropped as they are parsed
+//
+// Once its matchers are registered a Parser can parse several nodes at once.
+type Parser struct {
+	matchers      []EventMatcher
+	Context       int
+	MaxLineLength int
+	Progress      io.Writer
+	Window        Window
+}
+
+// NewParser returns a Parser that looks for all the built in events
+func NewParser() *Parser {
+	return &Parser{matchers: append([]EventMatcher{}, builtinMatchers...)}
+}
+
+// RegisterMatcher adds a matcher for an event the built in matchers don't
+// find. Lines are offered to the matchers in the order they were added.
+func (p *Parser) RegisterMatcher(eventMatcher EventMatcher) error {
+	for _, known := range p.matchers {
+		if known.Description == eventMatcher.Description {
+			return fmt.Errorf("%q is already an event", eventMatcher.Description)
+		}
+	}
+	p.matchers = append(p.matchers, eventMatcher)
+	return nil
+}
+
+// Matchers are the matchers the parser looks for, in the order they are tried
+func (p *Parser) Matchers() []EventMatcher {
+	return p.matchers
+}
+
+// ParseNode reads all the logs of a node and returns the events sorted.
+// Anything the logs reveal about the node, such as its address, is recorded
+// on it.
+func (p *Parser) ParseNode(index int, n *Node) (Timeline, []ParseWarning, error) {
+	stream := p.StreamNode(index, *n)
+	stream.node = n
+
+	events, err := ReadTimeline(stream)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events.Sort()
+	return events, stream.Warnings(), nil
+}
+
+// ParseReader reads the events in a single log of the node with the index,
+// and returns them sorted
+func (p *Parser) ParseReader(node int, r io.Reader) (Timeline, []ParseWarning) {
+	stream := p.newLogStream(node, nil, "", 0, r)
+
+	var events Timeline
+	for event := stream.next(); event != nil; event = stream.next() {
+		if p.Window.Contains(event.Datetime) {
+			events = append(events, event)
+		}
+	}
+
+	events.Sort()
+	return events, stream.warnings
+}
+
+// StreamNode reads the events of a node as they are parsed, for logs too big
+// to hold in memory. Each of the node's logs, with its rotated segments read
+// oldest first, is sorted within a window of reorderWindow events, enough for
+// a clock that went back a little. The logs, e.g. mysql.err.log and
+// innobackup.backup.log, are then merged. The node is not changed, use
+// Node.Identify first to find its name and address.
+func (p *Parser) StreamNode(index int, n Node) *NodeStream {
+	s := &NodeStream{parser: p, index: index}
+	var families []Stream
+	for _, family := range logFamilies(n.Logs) {
+		f := &familyStream{node: s, first: len(s.logs)}
+		s.logs = append(s.logs, family...)
+		f.end = len(s.logs)
+		f.opened = f.first
+		s.families = append(s.families, f)
+		families = append(families, f)
+	}
+	s.merged = MergeStreams(families...)
+	return s
+}
+
+// NodeStream is a Stream of the events of a node, from Parser.StreamNode
+//   - Parser that finds the events
+//   - Index of the node, and the node if it is being identified
+//   - Logs of the node, each log's rotated segments together and oldest first
+//   - Stream of each log and its segments, and the streams merged
+//   - Events that could not be parsed so far
+type NodeStream struct {
+	parser   *Parser
+	index    int
+	node     *Node
+	logs     []LogSource
+	families []*familyStream
+	merged   Stream
+	warnings []ParseWarning
+}
+
+func (s *NodeStream) Next() bool {
+	return s.merged.Next()
+}
+
+func (s *NodeStream) Event() *Event {
+	return s.merged.Event()
+}
+
+func (s *NodeStream) Err() error {
+	return s.merged.Err()
+}
+
+// Warnings are the events that could not be parsed in the logs read so far
+func (s *NodeStream) Warnings() []ParseWarning {
+	return s.warnings
+}
+
+// Close closes the logs being read, if the stream is not read to the end
+func (s *NodeStream) Close() error {
+	var err error
+	for _, f := range s.families {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// familyStream reads the segments of one of a node's logs, logs[first:end] of
+// the NodeStream, one after another
+//   - Node the log belongs to
+//   - Range of the node's logs, and the next to be opened
+//   - Segment being read
+//   - Events read but not yet returned, to be sorted
+//   - Current event
+//   - Why the log could not be read
+type familyStream struct {
+	node   *NodeStream
+	first  int
+	end    int
+	opened int
+	file   io.ReadCloser
+	log    *logStream
+	window eventHeap
+	event  *Event
+	err    error
+}
+
+func (s *familyStream) Next() bool {
+	for len(s.window) < reorderWindow {
+		event := s.read()
+		if event == nil {
+			break
+		}
+		heap.Push(&s.window, event)
+	}
+	if s.err != nil || len(s.window) == 0 {
+		return false
+	}
+
+	s.event = heap.Pop(&s.window).(*Event)
+	return true
+}
+
+// read returns the next event in the order it was logged, or nil at the end
+// of the log
+func (s *familyStream) read() *Event {
+	n := s.node
+	for s.err == nil {
+		if s.log != nil {
+			if event := s.log.next(); event != nil {
+				if !n.parser.Window.Contains(event.Datetime) {
+					continue
+				}
+				return event
+			}
+			n.warnings = append(n.warnings, s.log.warnings...)
+			s.Close()
+		}
+
+		if s.opened == s.end {
+			return nil
+		}
+
+		source := n.logs[s.opened]
+		if n.parser.Window.excludesLog(n.logs[s.first:s.end], s.opened-s.first) {
+			if n.parser.Progress != nil {
+				fmt.Fprintf(n.parser.Progress, "Skipping file %s, outside the window\n", source)
+			}
+			s.opened++
+			continue
+		}
+		if n.parser.Progress != nil {
+			fmt.Fprintf(n.parser.Progress, "Parsing file %s\n", source)
+		}
+		// A segment that can't be opened, e.g. a corrupt .gz, is skipped
+		file, err := source.Open()
+		if err != nil {
+			n.warnings = append(n.warnings, ParseWarning{source.String(), 0, "", err})
+			s.opened++
+			continue
+		}
+		s.file = file
+		s.log = n.parser.newLogStream(n.index, n.node, source.String(), s.opened, file)
+		s.opened++
+	}
+	return nil
+}
+
+func (s *familyStream) Event() *Event {
+	return s.event
+}
+
+func (s *familyStream) Err() error {
+	return s.err
+}
+
+// Close closes the segment being read
+func (s *familyStream) Close() error {
+	if s.log == nil {
+		return nil
+	}
+	s.log = nil
+	return s.file.Close()
+}
+
+// logStream finds the events in one log as it is read
+//   - Parser that finds the events
+//   - Index of the node, and the node if it is being identified
+//   - Log, and its place among the node's logs
+//   - Scanner reading the log, and whether it has reached the end
+//   - Events found, waiting for the lines after them to be read for context
+//   - Last event found, for the time of events that can't be parsed
+//   - Events that could not be parsed
+type logStream struct {
+	parser    *Parser
+	index     int
+	node      *Node
+	source    string
+	fileIndex int
+	scanner   *Scanner
+	done      bool
+	queue     []*Event
+	previous  *Event
+	warnings  []ParseWarning
+}
+
+func (p *Parser) newLogStream(index int, n *Node, source string, fileIndex int, r io.Reader) *logStream {
+	return &logStream{
+		parser:    p,
+		index:     index,
+		node:      n,
+		source:    source,
+		fileIndex: fileIndex,
+		scanner:   newScanner(r, p.Context),
+	}
+}
+
+// next returns the next event in the log, or nil at the end of it
+func (s *logStream) next() *Event {
+	for {
+		if len(s.queue) > 0 && (s.done || len(s.queue[0].After) >= s.parser.Context) {
+			event := s.queue[0]
+			s.queue = s.queue[1:]
+			s.truncate(event)
+			return event
+		}
+		if s.done {
+			return nil
+		}
+		s.done = !s.scan()
+	}
+}
+
+// scan reads the next line, and the rest of its event if it starts one
+func (s *logStream) scan() bool {
+	if !s.scanner.Next() {
+		if err := s.scanner.Err(); err != nil {
+			s.warnings = append(s.warnings, ParseWarning{s.source, s.scanner.LineNo() + 1, "", err})
+		}
+		return false
+	}
+
+	line := s.scanner.Text()
+	if s.node != nil {
+		s.node.identify(line)
+	}
+	for _, eventMatcher := range s.parser.matchers {
+		if eventMatcher.Match(line) {
+			lineNo := s.scanner.LineNo()
+			event, err := eventMatcher.Get(s.scanner)
+			if err == errNotMatched {
+				continue
+			}
+			if err != nil {
+				s.warnings = append(s.warnings, ParseWarning{s.source, lineNo, eventMatcher.Description, err})
+				event = newUnparsedEvent(err, s.scanner.Consumed(), s.previous)
+			}
+			// The matcher parsed the lines normalized, keep them as logged
+			event.Raw = strings.Join(s.scanner.Consumed(), "\n")
+			event.Node = s.index
+			event.Type = eventMatcher.Description
+			event.Source = s.source
+			event.File = s.fileIndex
+			event.Line = lineNo
+			event.format = eventMatcher.Format
+			s.scanner.follow(event)
+			s.queue = append(s.queue, event)
+			s.previous = event
+			break
+		}
+	}
+
+	return true
+}
+
+// truncate shortens the log lines kept with the event, once they have all
+// been read, and the text of its fields, e.g. the error of a Slave SQL Error
+// that is the rest of the line
+func (s *logStream) truncate(event *Event) {
+	if s.parser.MaxLineLength <= 0 {
+		return
+	}
+	event.Raw = strings.Join(truncateLines(strings.Split(event.Raw, "\n"), s.parser.MaxLineLength), "\n")
+	event.Before = truncateLines(event.Before, s.parser.MaxLineLength)
+	event.After = truncateLines(event.After, s.parser.MaxLineLength)
+	for name, value := range event.Fields {
+		if text, ok := value.(string); ok {
+			event.Fields[name] = truncateLine(text, s.parser.MaxLineLength)
+		}
+	}
+}
+
+// newUnparsedEvent keeps an event that an EventMatcher failed to parse on the
+// timeline, so the raw lines can still be found. If the lines have no usable
+// timestamp the event is placed after the previous event from the same file.
+func newUnparsedEvent(parseError error, lines []string, previous *Event) *Event {
+	eventTime, err := GetTimeAny(lines[0])
+	if err != nil && previous != nil {
+		eventTime = previous.Datetime
+	}
+
+	fields := Fields{"parse_error": parseError.Error()}
+
+	return NewEvent(eventTime, 0, SeverityWarning, fields, lines)
+}
@@ -0,0 +1,195 @@
+package timeline
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamNode(t *testing.T) {
+	dir := t.TempDir()
+	shift := func(clock string) string {
+		return "2017-06-14 " + clock + " 1 [Note] WSREP: Shifting SYNCED -> DONOR/DESYNCED (TO: 5)\n"
+	}
+	logs := []LogSource{
+		newLogSource(writeLog(t, dir, "mysql.err.log", shift("10:00:00")+shift("10:00:03")+shift("10:00:02"))),
+		newLogSource(writeLog(t, dir, "innobackup.backup.log", shift("10:00:01"))),
+		newLogSource(writeLog(t, dir, "mysql.err.log.1.gz", shift("09:00:00")+shift("09:59:00"))),
+	}
+	node := Node{Path: dir, Logs: logs}
+
+	tests := []struct {
+		name   string
+		window Window
+		want   []string
+	}{
+		{
+			"whole logs",
+			Window{},
+			[]string{
+				"09:00:00 mysql.err.log.1.gz:1",
+				"09:59:00 mysql.err.log.1.gz:2",
+				"10:00:00 mysql.err.log:1",
+				"10:00:01 innobackup.backup.log:1",
+				"10:00:02 mysql.err.log:3",
+				"10:00:03 mysql.err.log:2",
+			},
+		},
+		{
+			"window",
+			Window{Since: time.Date(2017, 6, 14, 10, 0, 1, 0, time.UTC), Until: time.Date(2017, 6, 14, 10, 0, 2, 0, time.UTC)},
+			[]string{
+				"10:00:01 innobackup.backup.log:1",
+				"10:00:02 mysql.err.log:3",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		parser := NewParser()
+		parser.Window = test.window
+		events, err := ReadTimeline(parser.StreamNode(1, node))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		var got []string
+		for _, e := range events {
+			if e.Node != 1 {
+				t.Errorf("%s: event of node %d, want 1", test.name, e.Node)
+			}
+			got = append(got, fmt.Sprintf("%s %s:%d", e.Datetime.Format("15:04:05"), filepath.Base(e.Source), e.Line))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got  %q\nwant %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestStreamNodeUnreadable(t *testing.T) {
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "mysql.err.log.2.gz")
+	if err := ioutil.WriteFile(corrupt, []byte("\x1f\x8b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	node := Node{Path: dir, Logs: []LogSource{
+		newLogSource(corrupt),
+		newLogSource(writeLog(t, dir, "mysql.err.log.1.gz", "2017-06-14 09:00:00 1 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 5)\n")),
+		newLogSource(writeLog(t, dir, "mysql.err.log", "2017-06-14 10:00:00 1 [Note] WSREP: Shifting OPEN -> PRIMARY (TO: 5)\n")),
+	}}
+
+	stream := NewParser().StreamNode(0, node)
+	events, err := ReadTimeline(stream)
+	if err != nil {
+		t.Fatalf("got error %s, want the unreadable log skipped", err)
+	}
+	if len(events) != 2 {
+		t.Errorf("got %d events, want those of the readable logs", len(events))
+	}
+
+	warnings := stream.Warnings()
+	if len(warnings) != 1 || warnings[0].File != corrupt || warnings[0].Line != 0 || warnings[0].Matcher != "" {
+		t.Fatalf("got warnings %v, want one for %s", warnings, corrupt)
+	}
+	if want := corrupt + ": could not be read: unexpected EOF"; warnings[0].String() != want {
+		t.Errorf("got %q, want %q", warnings[0].String(), want)
+	}
+}
+
+func TestMaxLineLength(t *testing.T) {
+	log := "2017-06-14 10:00:00 11 [ERROR] Slave SQL: Error 'Duplicate entry '1' for key 'PRIMARY'' on query. Default database: 'test'. Query: 'insert into t values (1)', Error_code: 1062\n" +
+		"2017-06-14 10:00:01 1 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 5)\n"
+
+	tests := []struct {
+		max   int
+		raw   string
+		error string
+	}{
+		{0, strings.SplitN(log, "\n", 2)[0], "Error 'Duplicate entry '1' for key 'PRIMARY'' on query. Default database: 'test'. Query: 'insert into t values (1)', Error_code: 1062"},
+		{40, "2017-06-14 10:00:00 11 [ERROR] Slave SQL... [135 bytes truncated]", "Error 'Duplicate entry '1' for key 'PRIM... [93 bytes truncated]"},
+	}
+
+	for _, test := range tests {
+		parser := NewParser()
+		parser.MaxLineLength = test.max
+		events, _ := parser.ParseReader(0, strings.NewReader(log))
+		if len(events) != 2 {
+			t.Fatalf("%d: got %d events, want 2", test.max, len(events))
+		}
+		if events[0].Raw != test.raw {
+			t.Errorf("%d: raw: got %q, want %q", test.max, events[0].Raw, test.raw)
+		}
+		if events[0].Fields["error"] != test.error {
+			t.Errorf("%d: error: got %q, want %q", test.max, events[0].Fields["error"], test.error)
+		}
+		if events[1].Fields["to"] != "OPEN" {
+			t.Errorf("%d: to: got %v, want the short field kept whole", test.max, events[1].Fields["to"])
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	lines := []string{
+		"2017-06-14 10:00:00 1 [Note] WSREP: Shifting OPEN -> PRIMARY (TO: 5)",
+		"2017-06-14 10:00:01 1 [Note] filler 1",
+		"2017-06-14 10:00:02 1 [Note] filler 2",
+		"2017-06-14 10:00:03 1 [Note] WSREP: Shifting PRIMARY -> JOINER (TO: 5)",
+		"2017-06-14 10:00:04 1 [Note] WSREP: Shifting JOINER -> JOINED (TO: 5)",
+		"2017-06-14 10:00:05 1 [Note] filler 3",
+		"2017-06-14 10:00:06 1 [Note] WSREP: State transfer required:",
+		"\tGroup state: f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1:30",
+		"\tLocal state: f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1:20",
+		"2017-06-14 10:00:07 1 [Note] filler 4",
+		"2017-06-14 10:00:08 1 [Note] WSREP: Shifting JOINED -> SYNCED (TO: 5)",
+	}
+	context := func(from int, to int) []string {
+		return lines[from-1 : to-1]
+	}
+
+	tests := []struct {
+		name   string
+		line   int
+		before []string
+		after  []string
+	}{
+		{"start of the log", 1, nil, context(2, 4)},
+		{"before another event", 4, context(2, 4), context(5, 7)},
+		{"after another event", 5, context(3, 5), context(6, 8)},
+		{"several lines", 7, context(5, 7), context(10, 12)},
+		{"end of the log", 11, context(9, 11), nil},
+	}
+
+	parser := NewParser()
+	parser.Context = 2
+	events, warnings := parser.ParseReader(0, strings.NewReader(strings.Join(lines, "\n")+"\n"))
+	if len(warnings) > 0 || len(events) != len(tests) {
+		t.Fatalf("got %d events and warnings %v, want %d events", len(events), warnings, len(tests))
+	}
+
+	for i, test := range tests {
+		e := events[i]
+		if e.Line != test.line {
+			t.Errorf("%s: got the event on line %d, want %d", test.name, e.Line, test.line)
+			continue
+		}
+		if len(e.Before) != len(test.before) || (len(test.before) > 0 && !reflect.DeepEqual(e.Before, test.before)) {
+			t.Errorf("%s: before: got %q, want %q", test.name, e.Before, test.before)
+		}
+		if len(e.After) != len(test.after) || (len(test.after) > 0 && !reflect.DeepEqual(e.After, test.after)) {
+			t.Errorf("%s: after: got %q, want %q", test.name, e.After, test.after)
+		}
+	}
+
+	// Without context none are kept
+	events, _ = NewParser().ParseReader(0, strings.NewReader(strings.Join(lines, "\n")+"\n"))
+	for _, e := range events {
+		if len(e.Before) > 0 || len(e.After) > 0 {
+			t.Errorf("line %d: got context %q and %q without --context", e.Line, e.Before, e.After)
+		}
+	}
+}
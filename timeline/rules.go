@@ -0,0 +1,311 @@
+package timeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// errNotMatched is returned by an EventMatcher that has no Signature when the
+// line is not one of its events, so the next matcher can try it
+var errNotMatched = errors.New("not matched")
+
+// Timestamp formats a rule can ask for
+var timestampFlavours = map[string]func(string) (time.Time, error){
+	"default":   GetTimeDefault,
+	"mysqld":    GetTimeMysqld,
+	"wsrep_sst": GetTimeWsrepSst,
+	"iso":       GetTimeISO,
+}
+
+// rulesFile is a YAML (or JSON) file of site specific events, given with --rules
+type rulesFile struct {
+	Rules []rule `yaml:"rules"`
+}
+
+// rule describes an event without writing Go
+//   - Description, which must not be the same as another matcher's
+//   - Substring to look for, and/or a regular expression that must match.
+//     Named groups in the regular expression become the event's fields.
+//   - Number of lines in the event, 1 by default
+//   - Timestamp format of the first line: default, mysqld, wsrep_sst or iso
+//   - Message template, e.g. "Lost {{ .peer | node }}"
+//   - Severity of the event, info by default
+//   - Severities for the event when a field matches, which also highlight the field
+type rule struct {
+	Description string          `yaml:"description"`
+	Signature   string          `yaml:"signature"`
+	Regex       string          `yaml:"regex"`
+	Lines       int             `yaml:"lines"`
+	Timestamp   string          `yaml:"timestamp"`
+	Message     string          `yaml:"message"`
+	Severity    string          `yaml:"severity"`
+	Highlight   []highlightRule `yaml:"highlight"`
+}
+
+// highlightRule raises the severity of an event when a field matches a
+// regular expression, and marks up the field in the message
+type highlightRule struct {
+	Field    string `yaml:"field"`
+	Matches  string `yaml:"matches"`
+	Severity string `yaml:"severity"`
+}
+
+type highlight struct {
+	field    string
+	matcher  *regexp.Regexp
+	severity Severity
+}
+
+func (h highlight) matches(fields Fields) bool {
+	value, ok := fields[h.field]
+	return ok && h.matcher.MatchString(fmt.Sprint(value))
+}
+
+// LoadRules reads a rules file and builds a matcher for each rule, to be
+// registered with a Parser
+func LoadRules(path string) ([]EventMatcher, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file rulesFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	var matchers []EventMatcher
+	for i, r := range file.Rules {
+		eventMatcher, err := r.eventMatcher()
+		if err != nil {
+			return nil, fmt.Errorf("%s: rule %d (%s): %s", path, i+1, r.Description, err)
+		}
+		matchers = append(matchers, eventMatcher)
+	}
+
+	return matchers, nil
+}
+
+// eventMatcher checks the rule and turns it in to an EventMatcher
+func (r rule) eventMatcher() (EventMatcher, error) {
+	if r.Description == "" {
+		return EventMatcher{}, errors.New("description is required")
+	}
+	if r.Signature == "" && r.Regex == "" {
+		return EventMatcher{}, errors.New("signature or regex is required")
+	}
+
+	var matcher *regexp.Regexp
+	if r.Regex != "" {
+		var err error
+		if matcher, err = regexp.Compile(r.Regex); err != nil {
+			return EventMatcher{}, err
+		}
+	}
+
+	count := r.Lines
+	if count == 0 {
+		count = 1
+	}
+	if count < 0 {
+		return EventMatcher{}, fmt.Errorf("lines must be at least 1, got %d", count)
+	}
+
+	flavour := r.Timestamp
+	if flavour == "" {
+		flavour = "default"
+	}
+	getTime, ok := timestampFlavours[flavour]
+	if !ok {
+		return EventMatcher{}, fmt.Errorf("unknown timestamp %q, expected default, mysqld, wsrep_sst or iso", flavour)
+	}
+
+	severity := SeverityInfo
+	if r.Severity != "" {
+		var err error
+		if severity, err = ParseSeverity(r.Severity); err != nil {
+			return EventMatcher{}, err
+		}
+	}
+
+	var highlights []highlight
+	for _, h := range r.Highlight {
+		if h.Field == "" {
+			return EventMatcher{}, errors.New("highlight needs a field")
+		}
+		highlightMatcher, err := regexp.Compile(h.Matches)
+		if err != nil {
+			return EventMatcher{}, fmt.Errorf("highlight %s: %s", h.Field, err)
+		}
+		highlightSeverity, err := ParseSeverity(h.Severity)
+		if err != nil {
+			return EventMatcher{}, fmt.Errorf("highlight %s: %s", h.Field, err)
+		}
+		highlights = append(highlights, highlight{h.Field, highlightMatcher, highlightSeverity})
+	}
+
+	message := r.Message
+	if message == "" {
+		message = r.Description
+	}
+	tmpl, err := template.New(r.Description).Option("missingkey=zero").Funcs(messageFuncs(NewPlainFormatter(nil), nil)).Parse(message)
+	if err != nil {
+		return EventMatcher{}, err
+	}
+	highlighted := make(map[string]bool)
+	for _, h := range highlights {
+		highlighted[h.field] = true
+	}
+	for _, t := range tmpl.Templates() {
+		highlightActions(t.Tree.Root, highlighted)
+	}
+	templates := &messageTemplates{tmpl: tmpl, highlights: highlights, formatters: make(map[*MessageFormatter]*template.Template)}
+
+	return EventMatcher{
+		r.Description,
+		r.Signature,
+		func(scanner *Scanner) (*Event, error) {
+			// Without a signature the regular expression picks the events
+			if r.Signature == "" && !matcher.MatchString(scanner.Text()) {
+				return nil, errNotMatched
+			}
+
+			lines, err := ScanLines(scanner, count)
+			if err != nil {
+				return nil, err
+			}
+			eventTime, err := getTime(lines[0])
+			if err != nil {
+				return nil, err
+			}
+
+			fields := Fields{}
+			if matcher != nil {
+				matches, err := findSubmatch(matcher, strings.Join(lines, "\n"))
+				if err != nil {
+					return nil, err
+				}
+				for i, name := range matcher.SubexpNames() {
+					if name != "" {
+						fields[name] = matches[i]
+					}
+				}
+			}
+
+			eventSeverity := severity
+			for _, h := range highlights {
+				if h.matches(fields) && h.severity > eventSeverity {
+					eventSeverity = h.severity
+				}
+			}
+
+			return NewEvent(eventTime, 0, eventSeverity, fields, lines), nil
+		},
+		func(e *Event, f *MessageFormatter) string {
+			values := make(map[string]string)
+			for name, value := range e.Fields {
+				values[name] = fmt.Sprint(value)
+			}
+
+			var message bytes.Buffer
+			if err := templates.get(f).Execute(&message, values); err != nil {
+				return f.Danger(err.Error())
+			}
+			return message.String()
+		},
+	}, nil
+}
+
+// messageFuncs are the functions available to rule message templates.
+// highlight marks up text when the value of the field matches one of the
+// highlights, with the highest severity that matches.
+func messageFuncs(f *MessageFormatter, highlights []highlight) template.FuncMap {
+	return template.FuncMap{
+		"danger":  f.Danger,
+		"success": f.Success,
+		"node":    f.NodeByAddress,
+		"uuid":    f.NodeByUUID,
+		"highlight": func(field string, value string, text string) string {
+			severity := SeverityInfo
+			for _, h := range highlights {
+				if h.field == field && h.matcher.MatchString(value) && h.severity > severity {
+					severity = h.severity
+				}
+			}
+			return f.Mark(severity, text)
+		},
+	}
+}
+
+// messageTemplates is the message template of a rule with the functions of
+// each MessageFormatter it has been used with, so it is cloned once per
+// formatter rather than for every event
+type messageTemplates struct {
+	mutex      sync.Mutex
+	tmpl       *template.Template
+	highlights []highlight
+	formatters map[*MessageFormatter]*template.Template
+}
+
+func (m *messageTemplates) get(f *MessageFormatter) *template.Template {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	t, ok := m.formatters[f]
+	if !ok {
+		t = template.Must(m.tmpl.Clone()).Funcs(messageFuncs(f, m.highlights))
+		m.formatters[f] = t
+	}
+	return t
+}
+
+// highlightActions pipes the actions that print a highlighted field through
+// highlight, so the field is marked up after any lookup, e.g.
+// {{ .address | node }} becomes {{ .address | node | highlight "address" .address }}
+func highlightActions(list *parse.ListNode, fields map[string]bool) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.ActionNode:
+			if len(n.Pipe.Decl) > 0 {
+				continue
+			}
+			field, ok := n.Pipe.Cmds[0].Args[0].(*parse.FieldNode)
+			if !ok || len(field.Ident) != 1 || !fields[field.Ident[0]] {
+				continue
+			}
+			name := field.Ident[0]
+			n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+				NodeType: parse.NodeCommand,
+				Pos:      n.Pos,
+				Args: []parse.Node{
+					parse.NewIdentifier("highlight").SetPos(n.Pos),
+					&parse.StringNode{NodeType: parse.NodeString, Pos: n.Pos, Quoted: strconv.Quote(name), Text: name},
+					field.Copy(),
+				},
+			})
+		case *parse.IfNode:
+			highlightActions(n.List, fields)
+			highlightActions(n.ElseList, fields)
+		case *parse.RangeNode:
+			highlightActions(n.List, fields)
+			highlightActions(n.ElseList, fields)
+		case *parse.WithNode:
+			highlightActions(n.List, fields)
+			highlightActions(n.ElseList, fields)
+		}
+	}
+}
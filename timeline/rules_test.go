@@ -0,0 +1,203 @@
+package timeline
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRules writes a rules file to a temporary directory
+func writeRules(t *testing.T, rules string) string {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := ioutil.WriteFile(path, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRules(t *testing.T) {
+	path := writeRules(t, `rules:
+  - description: Galera listening
+    signature: "') listening at "
+    regex: 'listening at tcp://(?P<address>[^:]+):(?P<port>[0-9]+)'
+    message: "Listening on {{ .address }}:{{ .port }}"
+    highlight:
+      - field: address
+        matches: '^0\.0\.0\.0$'
+        severity: warning
+  - description: Galera connection established
+    regex: 'connection established to (?P<uuid>[0-9a-f]{8}) tcp://(?P<address>[^:]+)'
+    message: "Connected to {{ .address | node }}"
+    severity: success
+  - description: Purging binary logs
+    signature: "Purging binary logs"
+    timestamp: iso
+`)
+
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("got %d rules, want 3", len(rules))
+	}
+
+	nodes := []Node{{Name: "mysql-1", Address: "10.0.16.45"}}
+	tests := []struct {
+		rule     int
+		line     string
+		fields   Fields
+		severity Severity
+		message  string
+	}{
+		{
+			0,
+			"2017-06-14 14:21:50 140 [Note] WSREP: (1c21c3b4, 'tcp://0.0.0.0:4567') listening at tcp://0.0.0.0:4567",
+			Fields{"address": "0.0.0.0", "port": "4567"},
+			SeverityWarning,
+			"Listening on 0.0.0.0:4567",
+		},
+		{
+			0,
+			"2017-06-14 14:21:50 140 [Note] WSREP: (1c21c3b4, 'tcp://10.0.16.44:4567') listening at tcp://10.0.16.44:4567",
+			Fields{"address": "10.0.16.44", "port": "4567"},
+			SeverityInfo,
+			"Listening on 10.0.16.44:4567",
+		},
+		{
+			1,
+			"2017-06-14T14:21:52.000000Z 0 [Note] [MY-000000] [Galera] (1c21c3b4, 'tcp://0.0.0.0:4567') connection established to 8a7f3cd1 tcp://10.0.16.45:4567",
+			Fields{"uuid": "8a7f3cd1", "address": "10.0.16.45"},
+			SeveritySuccess,
+			"Connected to mysql-1 (10.0.16.45)",
+		},
+		{
+			2,
+			"2017-06-14T14:21:52Z 0 [Note] [MY-010000] [Server] Purging binary logs",
+			Fields{},
+			SeverityInfo,
+			"Purging binary logs",
+		},
+	}
+
+	for _, test := range tests {
+		scanner := newScanner(strings.NewReader(test.line), 0)
+		scanner.Next()
+		rule := rules[test.rule]
+		event, err := rule.Get(scanner)
+		if err != nil {
+			t.Errorf("%s: %s", rule.Description, err)
+			continue
+		}
+		if len(event.Fields) != len(test.fields) {
+			t.Errorf("%s: got fields %v, want %v", rule.Description, event.Fields, test.fields)
+		}
+		for name, value := range test.fields {
+			if event.Fields[name] != value {
+				t.Errorf("%s: %s: got %v, want %v", rule.Description, name, event.Fields[name], value)
+			}
+		}
+		if event.Severity != test.severity {
+			t.Errorf("%s: severity: got %s, want %s", rule.Description, event.Severity, test.severity)
+		}
+		if got := rule.Format(event, NewPlainFormatter(nodes)); got != test.message {
+			t.Errorf("%s: got %q, want %q", rule.Description, got, test.message)
+		}
+	}
+}
+
+func TestRuleHighlight(t *testing.T) {
+	path := writeRules(t, `rules:
+  - description: Galera connection established
+    regex: 'connection established to (?P<uuid>[0-9a-f]{8}) tcp://(?P<address>[^:]+):(?P<port>[0-9]+)'
+    message: "Connected to {{ .address | node }}{{ if .port }} on {{ .port }}{{ end }}, {{ .address }}"
+    highlight:
+      - field: address
+        matches: '^10\.'
+        severity: warning
+      - field: port
+        matches: '^4567$'
+        severity: success
+`)
+
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := []Node{{Name: "mysql-1", Address: "10.0.16.45"}}
+	formatter := NewMessageFormatter(
+		func(s string) string { return "<danger>" + s + "</danger>" },
+		func(s string) string { return "<success>" + s + "</success>" },
+		nodes,
+	)
+	tests := []struct {
+		line    string
+		message string
+	}{
+		{
+			"2017-06-14 14:21:52 0 [Note] WSREP: (1c21c3b4, 'tcp://0.0.0.0:4567') connection established to 8a7f3cd1 tcp://10.0.16.45:4567",
+			"Connected to <danger>mysql-1 (10.0.16.45)</danger> on <success>4567</success>, <danger>10.0.16.45</danger>",
+		},
+		{
+			"2017-06-14 14:21:52 0 [Note] WSREP: (1c21c3b4, 'tcp://0.0.0.0:4567') connection established to 8a7f3cd1 tcp://192.168.0.5:4568",
+			"Connected to 192.168.0.5 on 4568, 192.168.0.5",
+		},
+	}
+
+	for _, test := range tests {
+		scanner := newScanner(strings.NewReader(test.line), 0)
+		scanner.Next()
+		event, err := rules[0].Get(scanner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Twice, as the template is only cloned for the first message
+		for i := 0; i < 2; i++ {
+			if got := rules[0].Format(event, formatter); got != test.message {
+				t.Errorf("got  %q\nwant %q", got, test.message)
+			}
+		}
+	}
+}
+
+// registerRules loads a rules file and registers its matchers with a new Parser
+func registerRules(path string) error {
+	rules, err := LoadRules(path)
+	if err != nil {
+		return err
+	}
+	parser := NewParser()
+	for _, rule := range rules {
+		if err := parser.RegisterMatcher(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestLoadRulesErrors(t *testing.T) {
+	tests := []struct {
+		rules string
+		err   string
+	}{
+		{"rules:\n  - signature: x\n", "description is required"},
+		{"rules:\n  - description: x\n", "signature or regex is required"},
+		{"rules:\n  - description: x\n    regex: '('\n", "missing closing )"},
+		{"rules:\n  - description: x\n    signature: x\n    lines: -1\n", "lines must be at least 1"},
+		{"rules:\n  - description: x\n    signature: x\n    timestamp: unix\n", "unknown timestamp"},
+		{"rules:\n  - description: x\n    signature: x\n    severity: fatal\n", "unknown severity"},
+		{"rules:\n  - description: x\n    signature: x\n    message: '{{ .x'\n", "unclosed action"},
+		{"rules:\n  - description: Bootstrap\n    signature: x\n", "already an event"},
+		{"rules:\n  - description: x\n    signature: x\n  - description: x\n    signature: y\n", "already an event"},
+		{"rules:\n  - description: x\n    signatur: x\n", "not found in type"},
+	}
+
+	for _, test := range tests {
+		err := registerRules(writeRules(t, test.rules))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: got error %v, want %q", test.rules, err, test.err)
+		}
+	}
+}
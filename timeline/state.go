@@ -0,0 +1,257 @@
+package timeline
+
+import (
+	"fmt"
+	"time"
+)
+
+// States a node can be in besides the Galera states of its Shifting events
+const (
+	StateDown     = "DOWN"
+	StateStarting = "STARTING"
+)
+
+// galeraShifts are the shifts Galera makes, from each state to those it can
+// shift to. Any state can shift to ERROR.
+var galeraShifts = map[string][]string{
+	"CLOSED":         {"OPEN", "DESTROYED"},
+	"OPEN":           {"PRIMARY", "CLOSED"},
+	"PRIMARY":        {"JOINER", "JOINED", "OPEN", "CLOSED"},
+	"JOINER":         {"JOINED", "PRIMARY", "OPEN", "CLOSED"},
+	"JOINED":         {"SYNCED", "DONOR/DESYNCED", "PRIMARY", "OPEN", "CLOSED"},
+	"SYNCED":         {"DONOR/DESYNCED", "PRIMARY", "OPEN", "CLOSED"},
+	"DONOR/DESYNCED": {"JOINED", "PRIMARY", "OPEN", "CLOSED"},
+	"DONOR":          {"JOINED", "PRIMARY", "OPEN", "CLOSED"},
+	"ERROR":          {"CLOSED", "DESTROYED"},
+}
+
+// Kinds of Marker
+const (
+	MarkerBootstrap  = "bootstrap"
+	MarkerSST        = "sst"
+	MarkerIST        = "ist"
+	MarkerNonPrimary = "non-primary"
+	MarkerFatal      = "fatal"
+)
+
+// Marker is an event that stands out in the history of a node, such as a
+// bootstrap or a crash
+//   - What kind of event, one of the Marker constants
+//   - The event
+type Marker struct {
+	Kind  string
+	Event *Event
+}
+
+// StateSpan is a time a node spent in one state
+//   - State, a Galera state such as SYNCED, or DOWN or STARTING
+//   - When the node entered it, and when it left or the timeline ended
+type StateSpan struct {
+	State string
+	Start time.Time
+	End   time.Time
+}
+
+func (s StateSpan) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// StateTracker follows the state of each node through a timeline, from its
+// Shifting, startup, shutdown and PID ended events. Events that skip a state,
+// make a shift Galera doesn't, or show mysqld ending without a normal
+// shutdown, get a "problem" field and at least a warning severity.
+// Bootstraps, SSTs, ISTs, non-primary views and crashes are kept as markers.
+//   - Times each node spent in each state, in order
+//   - Events that were flagged
+//   - Events that stand out, in order
+//   - Nodes that have started a normal shutdown
+//   - When the first and last events happened, to end the last spans
+type StateTracker struct {
+	Spans    [][]StateSpan
+	Problems []*Event
+	Markers  []Marker
+	stopping map[int]bool
+	start    time.Time
+	end      time.Time
+}
+
+// NewStateTracker tracks the nodes of a timeline, by their index
+func NewStateTracker(nodes int) *StateTracker {
+	return &StateTracker{Spans: make([][]StateSpan, nodes), stopping: make(map[int]bool)}
+}
+
+// TrackStates passes each event of the stream to the tracker as it is read,
+// and finishes the tracker at the end of the stream
+func TrackStates(stream Stream, tracker *StateTracker) Stream {
+	return &trackingStream{stream, tracker}
+}
+
+type trackingStream struct {
+	Stream
+	tracker *StateTracker
+}
+
+func (s *trackingStream) Next() bool {
+	if !s.Stream.Next() {
+		s.tracker.Finish()
+		return false
+	}
+	s.tracker.Track(s.Stream.Event())
+	return true
+}
+
+// State is the state the node is in so far, empty if it isn't known yet
+func (t *StateTracker) State(node int) string {
+	if node >= len(t.Spans) || len(t.Spans[node]) == 0 {
+		return ""
+	}
+	spans := t.Spans[node]
+	return spans[len(spans)-1].State
+}
+
+// Track moves the node of the event to the state the event puts it in, if
+// any. Events must be tracked in the order they happened.
+func (t *StateTracker) Track(e *Event) {
+	if t.start.IsZero() || e.Datetime.Before(t.start) {
+		t.start = e.Datetime
+	}
+	if e.Datetime.After(t.end) {
+		t.end = e.Datetime
+	}
+	if _, ok := e.Fields["parse_error"]; ok {
+		return
+	}
+
+	current := t.State(e.Node)
+	stopped := current == "" || current == StateDown || current == "CLOSED" || current == "DESTROYED"
+
+	switch e.Type {
+	case "Node is changing state":
+		from, _ := e.Fields["from"].(string)
+		to, _ := e.Fields["to"].(string)
+		switch {
+		case !sameState(current, from):
+			t.flag(e, fmt.Sprintf("was %s, the shifts to %s are missing", current, from))
+		case !isGaleraShift(from, to):
+			t.flag(e, fmt.Sprintf("Galera does not shift from %s to %s", from, to))
+		}
+		t.move(e.Node, to, e.Datetime)
+
+	case "MySQL startup":
+		if !stopped {
+			t.flag(e, fmt.Sprintf("started while %s, the stop is missing", current))
+		}
+		t.move(e.Node, StateStarting, e.Datetime)
+		t.stopping[e.Node] = false
+
+	case "MySQL normal shutdown":
+		t.stopping[e.Node] = true
+
+	case "InnoDB shutdown complete":
+		t.move(e.Node, StateDown, e.Datetime)
+
+	case "MySQL ended":
+		if current != "" && current != StateDown && !t.stopping[e.Node] {
+			t.flag(e, fmt.Sprintf("ended while %s, without a normal shutdown", current))
+			t.mark(MarkerFatal, e)
+		}
+		t.move(e.Node, StateDown, e.Datetime)
+
+	case "Bootstrap":
+		t.mark(MarkerBootstrap, e)
+
+	case "xtrabackup":
+		t.mark(MarkerSST, e)
+
+	case "IST Received":
+		t.mark(MarkerIST, e)
+
+	case "Cluster View":
+		if e.Fields["status"] == "NON_PRIM" {
+			t.mark(MarkerNonPrimary, e)
+		}
+
+	case "Fatal Error", "Assertion Failure":
+		t.mark(MarkerFatal, e)
+	}
+}
+
+func (t *StateTracker) mark(kind string, e *Event) {
+	t.Markers = append(t.Markers, Marker{kind, e})
+}
+
+// Period is when the tracked events started and ended
+func (t *StateTracker) Period() (time.Time, time.Time) {
+	return t.start, t.end
+}
+
+// sameState is true if a shift from the state could follow the current state
+func sameState(current, from string) bool {
+	switch current {
+	case "", from:
+		return true
+	case StateDown, StateStarting:
+		return from == "CLOSED"
+	}
+	return false
+}
+
+func isGaleraShift(from, to string) bool {
+	if to == "ERROR" {
+		return true
+	}
+	for _, next := range galeraShifts[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *StateTracker) flag(e *Event, problem string) {
+	if e.Fields == nil {
+		e.Fields = Fields{}
+	}
+	e.Fields["problem"] = problem
+	if e.Severity < SeverityWarning {
+		e.Severity = SeverityWarning
+	}
+	t.Problems = append(t.Problems, e)
+}
+
+// move ends the node's current span and starts one in the state, unless it is
+// already in it
+func (t *StateTracker) move(node int, state string, at time.Time) {
+	for node >= len(t.Spans) {
+		t.Spans = append(t.Spans, nil)
+	}
+	if t.State(node) == state {
+		return
+	}
+
+	spans := t.Spans[node]
+	if len(spans) > 0 {
+		spans[len(spans)-1].End = at
+	}
+	t.Spans[node] = append(spans, StateSpan{state, at, time.Time{}})
+}
+
+// Finish ends the state each node is in at the time of the last event
+func (t *StateTracker) Finish() {
+	for _, spans := range t.Spans {
+		if len(spans) > 0 && spans[len(spans)-1].End.IsZero() {
+			spans[len(spans)-1].End = t.end
+		}
+	}
+}
+
+// Durations totals the time the node spent in each state
+func (t *StateTracker) Durations(node int) map[string]time.Duration {
+	durations := make(map[string]time.Duration)
+	if node < len(t.Spans) {
+		for _, span := range t.Spans[node] {
+			durations[span.State] += span.Duration()
+		}
+	}
+	return durations
+}
@@ -0,0 +1,182 @@
+package timeline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// trackLogs tracks the states of the nodes through their logs, a log per node
+func trackLogs(t *testing.T, logs ...string) *StateTracker {
+	t.Helper()
+
+	parser := NewParser()
+	var streams []Stream
+	for i, log := range logs {
+		events, warnings := parser.ParseReader(i, strings.NewReader(log))
+		if len(warnings) > 0 {
+			t.Fatalf("node %d: unexpected warnings %v", i, warnings)
+		}
+		streams = append(streams, events.Stream())
+	}
+
+	states := NewStateTracker(len(logs))
+	events := TrackStates(MergeStreams(streams...), states)
+	for events.Next() {
+	}
+	return states
+}
+
+func at(clock string) time.Time {
+	t, err := time.Parse(timeFormatDefault, "2017-06-14 "+clock)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestStateTrackerSpans(t *testing.T) {
+	states := trackLogs(t, `2017-06-14 10:00:00 1 [Note] /usr/sbin/mysqld (mysqld 10.1.18-MariaDB) starting as process 1 ...
+2017-06-14 10:00:01 1 [Note] WSREP: Shifting CLOSED -> OPEN (TO: 0)
+2017-06-14 10:00:02 1 [Note] WSREP: Shifting OPEN -> PRIMARY (TO: 10)
+2017-06-14 10:00:03 1 [Note] WSREP: Shifting PRIMARY -> JOINER (TO: 10)
+2017-06-14 10:00:10 1 [Note] WSREP: Shifting JOINER -> JOINED (TO: 10)
+2017-06-14 10:00:11 1 [Note] WSREP: Shifting JOINED -> SYNCED (TO: 10)
+2017-06-14 10:10:00 1 [Note] /usr/sbin/mysqld: Normal shutdown
+2017-06-14 10:10:01 1 [Note] WSREP: Shifting SYNCED -> CLOSED (TO: 20)
+2017-06-14 10:10:05 1 [Note] /usr/sbin/mysqld: Shutdown complete
+170614 10:10:06 mysqld_safe mysqld from pid file /var/run/mysqld/mysqld.pid ended
+`)
+
+	want := []StateSpan{
+		{StateStarting, at("10:00:00"), at("10:00:01")},
+		{"OPEN", at("10:00:01"), at("10:00:02")},
+		{"PRIMARY", at("10:00:02"), at("10:00:03")},
+		{"JOINER", at("10:00:03"), at("10:00:10")},
+		{"JOINED", at("10:00:10"), at("10:00:11")},
+		{"SYNCED", at("10:00:11"), at("10:10:01")},
+		{"CLOSED", at("10:10:01"), at("10:10:05")},
+		{StateDown, at("10:10:05"), at("10:10:06")},
+	}
+	if !reflect.DeepEqual(states.Spans[0], want) {
+		t.Errorf("spans:\n got %v\nwant %v", states.Spans[0], want)
+	}
+	if len(states.Problems) > 0 {
+		t.Errorf("unexpected problems %v", states.Problems)
+	}
+	if got := states.Durations(0)["SYNCED"]; got != 9*time.Minute+50*time.Second {
+		t.Errorf("time SYNCED: got %s, want 9m50s", got)
+	}
+	if got := states.State(0); got != StateDown {
+		t.Errorf("last state: got %q, want %q", got, StateDown)
+	}
+}
+
+func TestStateTrackerFlags(t *testing.T) {
+	const (
+		startup  = "2017-06-14 10:00:00 1 [Note] /usr/sbin/mysqld (mysqld 10.1.18-MariaDB) starting as process 1 ...\n"
+		open     = "2017-06-14 10:00:01 1 [Note] WSREP: Shifting CLOSED -> OPEN (TO: 0)\n"
+		shutdown = "2017-06-14 10:00:05 1 [Note] /usr/sbin/mysqld: Normal shutdown\n"
+		ended    = "170614 10:00:06 mysqld_safe mysqld from pid file /var/run/mysqld/mysqld.pid ended\n"
+	)
+
+	tests := []struct {
+		name     string
+		log      string
+		problems []string
+		fatal    bool
+	}{
+		{
+			"shifts are missing",
+			startup + open + "2017-06-14 10:00:02 1 [Note] WSREP: Shifting JOINER -> JOINED (TO: 10)\n",
+			[]string{"was OPEN, the shifts to JOINER are missing"},
+			false,
+		},
+		{
+			"shift Galera does not make",
+			startup + open + "2017-06-14 10:00:02 1 [Note] WSREP: Shifting OPEN -> SYNCED (TO: 10)\n",
+			[]string{"Galera does not shift from OPEN to SYNCED"},
+			false,
+		},
+		{
+			"started without stopping",
+			startup + open + "2017-06-14 10:00:02 1 [Note] /usr/sbin/mysqld (mysqld 10.1.18-MariaDB) starting as process 2 ...\n",
+			[]string{"started while OPEN, the stop is missing"},
+			false,
+		},
+		{
+			"ended without a normal shutdown",
+			startup + open + ended,
+			[]string{"ended while OPEN, without a normal shutdown"},
+			true,
+		},
+		{
+			"ended after a normal shutdown",
+			startup + open + shutdown + ended,
+			nil,
+			false,
+		},
+		{
+			"log starts part way through",
+			"2017-06-14 10:00:02 1 [Note] WSREP: Shifting SYNCED -> DONOR/DESYNCED (TO: 10)\n",
+			nil,
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			states := trackLogs(t, test.log)
+
+			var problems []string
+			for _, e := range states.Problems {
+				problems = append(problems, e.Fields["problem"].(string))
+				if e.Severity < SeverityWarning {
+					t.Errorf("%s: severity %s, want at least warning", e.Type, e.Severity)
+				}
+			}
+			if !reflect.DeepEqual(problems, test.problems) {
+				t.Errorf("problems: got %q, want %q", problems, test.problems)
+			}
+
+			fatal := false
+			for _, marker := range states.Markers {
+				fatal = fatal || marker.Kind == MarkerFatal
+			}
+			if fatal != test.fatal {
+				t.Errorf("fatal marker: got %t, want %t", fatal, test.fatal)
+			}
+		})
+	}
+}
+
+func TestStateTrackerMarkers(t *testing.T) {
+	states := trackLogs(t, `2017-06-14 10:00:00 1 [Note] WSREP: 'wsrep-new-cluster' option used, bootstrapping the cluster
+2017-06-14 10:00:01 1 [Note] WSREP: Running: 'wsrep_sst_xtrabackup-v2 --role 'donor' --address '10.0.0.2:4444/xtrabackup_sst//1' --socket '/tmp/mysql.sock' '
+2017-06-14 10:00:02 1 [Note] WSREP: IST received: f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1:10
+2017-06-14 10:00:03 1 [Note] WSREP: view(view_id(NON_PRIM,1c21c3b4,2) memb {
+	1c21c3b4,0
+} joined {
+} left {
+} partitioned {
+	8a7f3cd1,0
+})
+2017-06-14 10:00:04 1 [Note] WSREP: view(view_id(PRIM,1c21c3b4,3) memb {
+	1c21c3b4,0
+	8a7f3cd1,0
+} joined {
+} left {
+} partitioned {
+})
+`)
+
+	var kinds []string
+	for _, marker := range states.Markers {
+		kinds = append(kinds, marker.Kind)
+	}
+	want := []string{MarkerBootstrap, MarkerSST, MarkerIST, MarkerNonPrimary}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("markers: got %q, want %q", kinds, want)
+	}
+}
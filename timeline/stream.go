@@ -0,0 +1,259 @@
+package timeline
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+// Stream is a source of events read one at a time, like a bufio.Scanner, so
+// logs too big for memory can be rendered
+//
+//	for stream.Next() {
+//		event := stream.Event()
+//	}
+//	if err := stream.Err(); err != nil {
+type Stream interface {
+	Next() bool
+	Event() *Event
+	Err() error
+}
+
+// Stream reads the events of the timeline
+func (t Timeline) Stream() Stream {
+	return &timelineStream{timeline: t}
+}
+
+type timelineStream struct {
+	timeline Timeline
+	event    *Event
+}
+
+func (s *timelineStream) Next() bool {
+	if len(s.timeline) == 0 {
+		return false
+	}
+	s.event, s.timeline = s.timeline[0], s.timeline[1:]
+	return true
+}
+
+func (s *timelineStream) Event() *Event {
+	return s.event
+}
+
+func (s *timelineStream) Err() error {
+	return nil
+}
+
+// ReadTimeline reads all of the events in a stream
+func ReadTimeline(stream Stream) (Timeline, error) {
+	var timeline Timeline
+	for stream.Next() {
+		timeline = append(timeline, stream.Event())
+	}
+	return timeline, stream.Err()
+}
+
+// Background reads the stream in its own goroutine, up to size events ahead,
+// so several streams can be parsed at once while they are merged. The stream
+// must be read to the end.
+func Background(stream Stream, size int) Stream {
+	background := &backgroundStream{events: make(chan *Event, size)}
+	go func() {
+		for stream.Next() {
+			background.events <- stream.Event()
+		}
+		background.err = stream.Err()
+		close(background.events)
+	}()
+	return background
+}
+
+type backgroundStream struct {
+	events chan *Event
+	event  *Event
+	err    error
+}
+
+func (s *backgroundStream) Next() bool {
+	event, ok := <-s.events
+	s.event = event
+	return ok
+}
+
+func (s *backgroundStream) Event() *Event {
+	return s.event
+}
+
+// Err is only set once the events have all been read
+func (s *backgroundStream) Err() error {
+	return s.err
+}
+
+// FilterStream reads the events of the stream that keep returns true for
+func FilterStream(stream Stream, keep func(*Event) bool) Stream {
+	return &filterStream{stream, keep}
+}
+
+type filterStream struct {
+	Stream
+	keep func(*Event) bool
+}
+
+func (s *filterStream) Next() bool {
+	for s.Stream.Next() {
+		if s.keep(s.Stream.Event()) {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeStreams merges sorted streams, e.g. of each node, in to one sorted
+// stream. Only the next event of each stream is held in memory.
+func MergeStreams(streams ...Stream) Stream {
+	return &mergeStream{streams: streams}
+}
+
+// mergeStream keeps the streams with events left in a heap, the stream with
+// the earliest next event first. That stream is moved on to its next event
+// when the merged stream is.
+type mergeStream struct {
+	streams []Stream
+	heap    streamHeap
+	started bool
+	err     error
+}
+
+func (m *mergeStream) Next() bool {
+	if !m.started {
+		m.started = true
+		for _, stream := range m.streams {
+			m.push(stream)
+		}
+	} else if len(m.heap) > 0 {
+		if m.heap[0].Next() {
+			heap.Fix(&m.heap, 0)
+		} else {
+			m.done(heap.Pop(&m.heap).(Stream))
+		}
+	}
+	return m.err == nil && len(m.heap) > 0
+}
+
+func (m *mergeStream) push(stream Stream) {
+	if stream.Next() {
+		heap.Push(&m.heap, stream)
+	} else {
+		m.done(stream)
+	}
+}
+
+func (m *mergeStream) done(stream Stream) {
+	if err := stream.Err(); err != nil && m.err == nil {
+		m.err = err
+	}
+}
+
+func (m *mergeStream) Event() *Event {
+	return m.heap[0].Event()
+}
+
+func (m *mergeStream) Err() error {
+	return m.err
+}
+
+type streamHeap []Stream
+
+func (h streamHeap) Len() int           { return len(h) }
+func (h streamHeap) Less(i, j int) bool { return h[i].Event().precedes(h[j].Event()) }
+func (h streamHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *streamHeap) Push(x interface{}) {
+	*h = append(*h, x.(Stream))
+}
+
+func (h *streamHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// eventHeap holds events with the earliest first
+type eventHeap []*Event
+
+func (h eventHeap) Len() int           { return len(h) }
+func (h eventHeap) Less(i, j int) bool { return h[i].precedes(h[j]) }
+func (h eventHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *eventHeap) Push(x interface{}) {
+	*h = append(*h, x.(*Event))
+}
+
+func (h *eventHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// rowStream groups a sorted stream in to rows of events at the same time,
+// with a column per node. An event from a node without a column ends the
+// stream with an error.
+type rowStream struct {
+	events  Stream
+	nodes   int
+	started bool
+	next    *Event
+	err     error
+	Time    string
+	Columns [][]*Event
+}
+
+func newRowStream(events Stream, nodes int) *rowStream {
+	return &rowStream{events: events, nodes: nodes}
+}
+
+func (r *rowStream) Next() bool {
+	if !r.started {
+		r.started = true
+		r.advance()
+	}
+	if r.next == nil || r.err != nil {
+		return false
+	}
+
+	r.Time = r.next.Datetime.Format(timeFormatRow)
+	r.Columns = make([][]*Event, r.nodes)
+	for r.next != nil && r.next.Datetime.Format(timeFormatRow) == r.Time {
+		if r.err = checkNode(r.next, r.nodes); r.err != nil {
+			return false
+		}
+		r.Columns[r.next.Node] = append(r.Columns[r.next.Node], r.next)
+		r.advance()
+	}
+	return true
+}
+
+func (r *rowStream) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.events.Err()
+}
+
+func (r *rowStream) advance() {
+	r.next = nil
+	if r.events.Next() {
+		r.next = r.events.Event()
+	}
+}
+
+// checkNode returns an error if the event is from a node that was not given
+// to the renderer
+func checkNode(event *Event, nodes int) error {
+	if event.Node < 0 || event.Node >= nodes {
+		return fmt.Errorf("%s:%d: event from node %d, but only %d nodes were given", event.Source, event.Line, event.Node, nodes)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package timeline
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergeStreams(t *testing.T) {
+	start := time.Date(2017, 6, 14, 10, 0, 0, 0, time.UTC)
+	event := func(seconds int, node int, file int, line int) *Event {
+		e := NewEvent(start.Add(time.Duration(seconds)*time.Second), node, SeverityInfo, Fields{}, []string{""})
+		e.File = file
+		e.Line = line
+		return e
+	}
+	place := func(e *Event) [4]int {
+		return [4]int{int(e.Datetime.Sub(start) / time.Second), e.Node, e.File, e.Line}
+	}
+
+	tests := []struct {
+		name      string
+		timelines []Timeline
+		want      [][4]int
+	}{
+		{"none", nil, nil},
+		{"empty", []Timeline{{}, {}}, nil},
+		{
+			"interleaved",
+			[]Timeline{
+				{event(0, 0, 0, 1), event(2, 0, 0, 2), event(4, 0, 0, 3)},
+				{event(1, 1, 0, 1), event(3, 1, 0, 2)},
+				{},
+			},
+			[][4]int{{0, 0, 0, 1}, {1, 1, 0, 1}, {2, 0, 0, 2}, {3, 1, 0, 2}, {4, 0, 0, 3}},
+		},
+		{
+			"same time by node, log and line",
+			[]Timeline{
+				{event(0, 1, 0, 5)},
+				{event(0, 0, 1, 1), event(0, 0, 1, 2)},
+				{event(0, 0, 0, 9)},
+			},
+			[][4]int{{0, 0, 0, 9}, {0, 0, 1, 1}, {0, 0, 1, 2}, {0, 1, 0, 5}},
+		},
+	}
+
+	for _, test := range tests {
+		var streams []Stream
+		for _, timeline := range test.timelines {
+			streams = append(streams, timeline.Stream())
+		}
+
+		merged, err := ReadTimeline(MergeStreams(streams...))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		var got [][4]int
+		for _, e := range merged {
+			got = append(got, place(e))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRenderNodes(t *testing.T) {
+	log := "2017-06-14 10:00:00 1 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 5)\n"
+	nodes := []Node{{Name: "node 0"}, {Name: "node 1"}, {Name: "node 2"}}
+
+	renderers := []struct {
+		name     string
+		renderer Renderer
+	}{
+		{"text", TextRenderer{}},
+		{"html", HTMLRenderer{}},
+		{"json", JSONRenderer{}},
+		{"ndjson", NDJSONRenderer{}},
+	}
+	tests := []struct {
+		name    string
+		node    int
+		nodes   []Node
+		wantErr bool
+	}{
+		{"node given", 2, nodes, false},
+		{"node not given", 5, nodes, true},
+		{"no nodes", 0, nil, true},
+	}
+
+	for _, r := range renderers {
+		for _, test := range tests {
+			events, _ := NewParser().ParseReader(test.node, strings.NewReader(log))
+			err := r.renderer.Render(ioutil.Discard, events.Stream(), test.nodes)
+			if (err != nil) != test.wantErr {
+				t.Errorf("%s: %s: got error %v, want error %t", r.name, test.name, err, test.wantErr)
+			}
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package timeline
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// States a node is in while it is part of a primary component
+var primaryStates = map[string]bool{
+	"PRIMARY":        true,
+	"JOINER":         true,
+	"JOINED":         true,
+	"SYNCED":         true,
+	"DONOR/DESYNCED": true,
+	"DONOR":          true,
+}
+
+// How far apart the two sides of a state transfer can be logged and still be
+// paired up
+const transferSlack = time.Minute
+
+// Period is a time between two moments. Open is set when it lasted until the
+// logs ended.
+type Period struct {
+	Start time.Time
+	End   time.Time
+	Open  bool
+}
+
+func (p Period) Duration() time.Duration {
+	return p.End.Sub(p.Start)
+}
+
+// Transfer is a state transfer from a donor to a joiner
+//   - SST or IST, or empty if the logs don't say
+//   - Index of the donor and joiner, -1 if they aren't known
+//   - Address of the joiner, when it isn't one of the nodes
+//   - When it started and ended, and whether the joiner joined
+type Transfer struct {
+	Kind          string
+	Donor         int
+	Joiner        int
+	JoinerAddress string
+	Period        Period
+	Finished      bool
+}
+
+// Crash is a node stopping without a normal shutdown, and the events that
+// show it, e.g. an assertion failure then the PID ending
+type Crash struct {
+	Node   int
+	Events []*Event
+}
+
+// Downtime is a time a node's mysqld was not running
+type Downtime struct {
+	Node   int
+	Period Period
+}
+
+// Summary answers the usual questions about an incident from the states of
+// the nodes
+//   - When the timeline started and ended
+//   - Times no node was in a primary component
+//   - Bootstraps, by node
+//   - State transfers
+//   - Crashes
+//   - Times each node was down, longest first
+type Summary struct {
+	Start      time.Time
+	End        time.Time
+	NoPrimary  []Period
+	Bootstraps [][]*Event
+	Transfers  []Transfer
+	Crashes    []Crash
+	Downtimes  []Downtime
+}
+
+// Summarize works out the summary from a tracker that has seen the whole
+// timeline
+func Summarize(states *StateTracker, nodes []Node) *Summary {
+	lanes := len(nodes)
+	if len(states.Spans) > lanes {
+		lanes = len(states.Spans)
+	}
+
+	s := &Summary{Bootstraps: make([][]*Event, lanes)}
+	s.Start, s.End = states.Period()
+	s.NoPrimary = noPrimaryPeriods(states, s.End)
+
+	for _, marker := range states.Markers {
+		if marker.Kind == MarkerBootstrap {
+			s.Bootstraps[marker.Event.Node] = append(s.Bootstraps[marker.Event.Node], marker.Event)
+		}
+	}
+
+	s.Transfers = findTransfers(states, nodes)
+	s.Crashes = findCrashes(states)
+
+	for node, spans := range states.Spans {
+		for i, span := range spans {
+			if span.State == StateDown {
+				s.Downtimes = append(s.Downtimes, Downtime{node, Period{span.Start, span.End, i == len(spans)-1}})
+			}
+		}
+	}
+	sort.SliceStable(s.Downtimes, func(i, j int) bool {
+		return s.Downtimes[i].Period.Duration() > s.Downtimes[j].Period.Duration()
+	})
+
+	return s
+}
+
+// noPrimaryPeriods finds the times no node was in a primary component, from
+// when the first node's state is known. A node that isn't known yet is
+// counted as not in one.
+func noPrimaryPeriods(states *StateTracker, end time.Time) []Period {
+	var changes []time.Time
+	for _, spans := range states.Spans {
+		for _, span := range spans {
+			changes = append(changes, span.Start)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Before(changes[j])
+	})
+
+	var periods []Period
+	var current *Period
+	for _, at := range changes {
+		primary := false
+		for node := range states.Spans {
+			if primaryStates[stateAt(states.Spans[node], at)] {
+				primary = true
+			}
+		}
+
+		switch {
+		case !primary && current == nil:
+			current = &Period{Start: at}
+		case primary && current != nil:
+			current.End = at
+			if current.Duration() > 0 {
+				periods = append(periods, *current)
+			}
+			current = nil
+		}
+	}
+	if current != nil && end.After(current.Start) {
+		current.End = end
+		current.Open = true
+		periods = append(periods, *current)
+	}
+	return periods
+}
+
+// stateAt is the state the spans show at the time, empty if it isn't known
+func stateAt(spans []StateSpan, at time.Time) string {
+	state := ""
+	for _, span := range spans {
+		if span.Start.After(at) {
+			break
+		}
+		state = span.State
+	}
+	return state
+}
+
+// findTransfers pairs the times nodes spent as a JOINER with the SST and IST
+// events and the donors that gave them their state. SSTs whose joiner isn't
+// one of the nodes are found from the donor.
+func findTransfers(states *StateTracker, nodes []Node) []Transfer {
+	var transfers []Transfer
+	donated := make(map[*Event]bool)
+
+	for joiner, spans := range states.Spans {
+		for i, span := range spans {
+			if span.State != "JOINER" {
+				continue
+			}
+			transfer := Transfer{Donor: -1, Joiner: joiner, Period: Period{span.Start, span.End, i == len(spans)-1}}
+			transfer.Finished = i+1 < len(spans) && spans[i+1].State == "JOINED"
+
+			for _, marker := range states.Markers {
+				e := marker.Event
+				near := !e.Datetime.Before(span.Start.Add(-transferSlack)) && !e.Datetime.After(span.End.Add(transferSlack))
+				if !near {
+					continue
+				}
+				switch {
+				case marker.Kind == MarkerSST && e.Node == joiner && e.Fields["role"] == "joiner":
+					transfer.Kind = "SST"
+				case marker.Kind == MarkerIST && e.Node == joiner && transfer.Kind == "":
+					transfer.Kind = "IST"
+				case marker.Kind == MarkerSST && e.Node != joiner && e.Fields["role"] == "donor" && transfer.Donor == -1:
+					if address, _ := e.Fields["address"].(string); nodeByAddress(nodes, address) == joiner {
+						transfer.Donor = e.Node
+						donated[e] = true
+					}
+				}
+			}
+
+			if transfer.Donor == -1 {
+				transfer.Donor = donorAt(states, joiner, span.Start)
+			}
+			transfers = append(transfers, transfer)
+		}
+	}
+
+	// Joiners that aren't among the nodes only show up in their donor's log
+	for _, marker := range states.Markers {
+		e := marker.Event
+		if marker.Kind != MarkerSST || e.Fields["role"] != "donor" || donated[e] {
+			continue
+		}
+		address, _ := e.Fields["address"].(string)
+		if nodeByAddress(nodes, address) >= 0 {
+			continue
+		}
+		transfer := Transfer{Kind: "SST", Donor: e.Node, Joiner: -1, JoinerAddress: address, Period: Period{Start: e.Datetime, End: e.Datetime}}
+		spans := states.Spans[e.Node]
+		for i, span := range spans {
+			if strings.HasPrefix(span.State, "DONOR") && !span.Start.Before(e.Datetime.Add(-transferSlack)) && !span.Start.After(e.Datetime.Add(transferSlack)) {
+				transfer.Period = Period{span.Start, span.End, i == len(spans)-1}
+				transfer.Finished = !transfer.Period.Open
+				break
+			}
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	sort.SliceStable(transfers, func(i, j int) bool {
+		return transfers[i].Period.Start.Before(transfers[j].Period.Start)
+	})
+	return transfers
+}
+
+// donorAt is the node that became a donor closest to the time, -1 if none did
+// within transferSlack
+func donorAt(states *StateTracker, joiner int, at time.Time) int {
+	donor := -1
+	var closest time.Duration
+	for node, spans := range states.Spans {
+		if node == joiner {
+			continue
+		}
+		for _, span := range spans {
+			if !strings.HasPrefix(span.State, "DONOR") {
+				continue
+			}
+			distance := span.Start.Sub(at)
+			if distance < 0 {
+				distance = -distance
+			}
+			if distance <= transferSlack && (donor == -1 || distance < closest) {
+				donor, closest = node, distance
+			}
+		}
+	}
+	return donor
+}
+
+// findCrashes groups the fatal markers of each node by the startups between
+// them, so an assertion failure and the PID ending after it are one crash
+func findCrashes(states *StateTracker) []Crash {
+	var crashes []Crash
+	last := make(map[int]int)
+	for _, marker := range states.Markers {
+		if marker.Kind != MarkerFatal {
+			continue
+		}
+		e := marker.Event
+		if i, ok := last[e.Node]; ok && !startedBetween(states.Spans[e.Node], crashes[i].Events[len(crashes[i].Events)-1].Datetime, e.Datetime) {
+			crashes[i].Events = append(crashes[i].Events, e)
+			continue
+		}
+		last[e.Node] = len(crashes)
+		crashes = append(crashes, Crash{e.Node, []*Event{e}})
+	}
+	return crashes
+}
+
+func startedBetween(spans []StateSpan, from, to time.Time) bool {
+	for _, span := range spans {
+		if span.State == StateStarting && span.Start.After(from) && !span.Start.After(to) {
+			return true
+		}
+	}
+	return false
+}
+
+// summarySection is a heading of the summary and its lines
+type summarySection struct {
+	Title string
+	Lines []string
+}
+
+// sections describes the summary in words, the same in each output format
+func (s *Summary) sections(nodes []Node) []summarySection {
+	formatter := NewPlainFormatter(nodes)
+	label := func(node int) string {
+		if node >= 0 && node < len(nodes) {
+			return nodes[node].Label()
+		}
+		return "an unknown node"
+	}
+
+	var sections []summarySection
+
+	noPrimary := summarySection{Title: "No PRIMARY component"}
+	for _, period := range s.NoPrimary {
+		noPrimary.Lines = append(noPrimary.Lines, describePeriod(period))
+	}
+	sections = append(sections, noPrimary)
+
+	bootstraps := summarySection{Title: "Bootstraps"}
+	for node, events := range s.Bootstraps {
+		if len(events) == 0 {
+			continue
+		}
+		var times []string
+		for _, e := range events {
+			times = append(times, e.Datetime.Format(timeFormatDefault))
+		}
+		bootstraps.Lines = append(bootstraps.Lines, fmt.Sprintf("%s: %d (%s)", label(node), len(events), strings.Join(times, ", ")))
+	}
+	sections = append(sections, bootstraps)
+
+	transfers := summarySection{Title: "State transfers"}
+	for _, transfer := range s.Transfers {
+		kind := transfer.Kind
+		if kind == "" {
+			kind = "State transfer"
+		}
+		joiner := label(transfer.Joiner)
+		if transfer.Joiner == -1 && transfer.JoinerAddress != "" {
+			joiner = transfer.JoinerAddress
+		}
+		outcome := fmt.Sprintf("took %s", transfer.Period.Duration())
+		switch {
+		case transfer.Period.Open:
+			outcome = fmt.Sprintf("still running after %s when the logs end", transfer.Period.Duration())
+		case !transfer.Finished:
+			outcome = fmt.Sprintf("failed after %s", transfer.Period.Duration())
+		}
+		transfers.Lines = append(transfers.Lines, fmt.Sprintf("%s %s from %s to %s, %s", transfer.Period.Start.Format(timeFormatDefault), kind, label(transfer.Donor), joiner, outcome))
+	}
+	sections = append(sections, transfers)
+
+	crashes := summarySection{Title: "Crashes"}
+	for _, crash := range s.Crashes {
+		var messages []string
+		for _, e := range crash.Events {
+			messages = append(messages, formatter.Message(e))
+		}
+		crashes.Lines = append(crashes.Lines, fmt.Sprintf("%s %s: %s", crash.Events[0].Datetime.Format(timeFormatDefault), label(crash.Node), strings.Join(messages, "; ")))
+	}
+	sections = append(sections, crashes)
+
+	downtime := summarySection{Title: "Longest downtime"}
+	if len(s.Downtimes) > 0 {
+		longest := s.Downtimes[0]
+		downtime.Lines = append(downtime.Lines, fmt.Sprintf("%s: %s", label(longest.Node), describePeriod(longest.Period)))
+	}
+	sections = append(sections, downtime)
+
+	return sections
+}
+
+func describePeriod(p Period) string {
+	if p.Open {
+		return fmt.Sprintf("%s for %s, until the logs end", p.Start.Format(timeFormatDefault), p.Duration())
+	}
+	return fmt.Sprintf("%s to %s (%s)", p.Start.Format(timeFormatDefault), p.End.Format(timeFormatDefault), p.Duration())
+}
+
+// WriteText writes the summary as plain text
+func (s *Summary) WriteText(w io.Writer, nodes []Node) error {
+	for i, section := range s.sections(nodes) {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, section.Title)
+		if len(section.Lines) == 0 {
+			fmt.Fprintln(w, "  none")
+		}
+		for _, line := range section.Lines {
+			if _, err := fmt.Fprintf(w, "  %s\n", line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package timeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNoPrimaryPeriods(t *testing.T) {
+	tests := []struct {
+		name string
+		logs []string
+		want []Period
+	}{
+		{
+			"recovered",
+			[]string{
+				`2017-06-14 10:00:00 1 [Note] /usr/sbin/mysqld (mysqld 10.1.18-MariaDB) starting as process 1 ...
+2017-06-14 10:00:01 1 [Note] WSREP: Shifting CLOSED -> OPEN (TO: 0)
+2017-06-14 10:00:02 1 [Note] WSREP: Shifting OPEN -> PRIMARY (TO: 10)
+2017-06-14 10:00:03 1 [Note] WSREP: Shifting PRIMARY -> JOINED (TO: 10)
+2017-06-14 10:00:04 1 [Note] WSREP: Shifting JOINED -> SYNCED (TO: 10)
+2017-06-14 10:05:00 1 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 20)
+2017-06-14 10:07:00 1 [Note] WSREP: Shifting OPEN -> PRIMARY (TO: 20)
+`,
+				`2017-06-14 10:01:00 1 [Note] /usr/sbin/mysqld (mysqld 10.1.18-MariaDB) starting as process 1 ...
+2017-06-14 10:01:01 1 [Note] WSREP: Shifting CLOSED -> OPEN (TO: 0)
+2017-06-14 10:01:02 1 [Note] WSREP: Shifting OPEN -> PRIMARY (TO: 10)
+2017-06-14 10:01:03 1 [Note] WSREP: Shifting PRIMARY -> JOINER (TO: 10)
+2017-06-14 10:02:00 1 [Note] WSREP: Shifting JOINER -> JOINED (TO: 10)
+2017-06-14 10:02:01 1 [Note] WSREP: Shifting JOINED -> SYNCED (TO: 10)
+2017-06-14 10:05:01 1 [Note] WSREP: Shifting SYNCED -> OPEN (TO: 20)
+2017-06-14 10:09:00 1 [Note] WSREP: Shifting OPEN -> CLOSED (TO: 20)
+`,
+			},
+			[]Period{
+				{at("10:00:00"), at("10:00:02"), false},
+				{at("10:05:01"), at("10:07:00"), false},
+			},
+		},
+		{
+			"still without a primary component",
+			[]string{
+				`2017-06-14 10:00:00 1 [Note] /usr/sbin/mysqld (mysqld 10.1.18-MariaDB) starting as process 1 ...
+2017-06-14 10:00:01 1 [Note] WSREP: Shifting CLOSED -> OPEN (TO: 0)
+2017-06-14 10:00:02 1 [Note] WSREP: Shifting OPEN -> PRIMARY (TO: 10)
+2017-06-14 10:03:00 1 [Note] WSREP: Shifting PRIMARY -> OPEN (TO: 10)
+2017-06-14 10:04:00 1 [Note] WSREP: Shifting OPEN -> CLOSED (TO: 10)
+`,
+			},
+			[]Period{
+				{at("10:00:00"), at("10:00:02"), false},
+				{at("10:03:00"), at("10:04:00"), true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			states := trackLogs(t, test.logs...)
+			_, end := states.Period()
+			if got := noPrimaryPeriods(states, end); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v\nwant %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFindTransfers(t *testing.T) {
+	donor := `2017-06-14 10:00:00 1 [Note] WSREP: Shifting SYNCED -> DONOR/DESYNCED (TO: 10)
+2017-06-14 10:00:00 1 [Note] WSREP: Running: 'wsrep_sst_xtrabackup-v2 --role 'donor' --address '10.0.0.2:4444/xtrabackup_sst//1' --socket '/tmp/mysql.sock' '
+2017-06-14 10:02:00 1 [Note] WSREP: Shifting DONOR/DESYNCED -> JOINED (TO: 12)
+2017-06-14 10:02:01 1 [Note] WSREP: Shifting JOINED -> SYNCED (TO: 12)
+2017-06-14 10:30:00 1 [Note] WSREP: Shifting SYNCED -> DONOR/DESYNCED (TO: 20)
+2017-06-14 10:30:00 1 [Note] WSREP: Running: 'wsrep_sst_xtrabackup-v2 --role 'donor' --address '10.0.0.9:4444/xtrabackup_sst//1' --socket '/tmp/mysql.sock' '
+2017-06-14 10:31:00 1 [Note] WSREP: Shifting DONOR/DESYNCED -> JOINED (TO: 22)
+2017-06-14 10:31:01 1 [Note] WSREP: Shifting JOINED -> SYNCED (TO: 22)
+2017-06-14 10:40:02 1 [Note] WSREP: Shifting SYNCED -> DONOR/DESYNCED (TO: 30)
+2017-06-14 10:40:05 1 [Note] WSREP: Shifting DONOR/DESYNCED -> JOINED (TO: 30)
+`
+	joiner := `2017-06-14 09:59:59 1 [Note] WSREP: Shifting PRIMARY -> JOINER (TO: 10)
+2017-06-14 09:59:59 1 [Note] WSREP: Running: 'wsrep_sst_xtrabackup-v2 --role 'joiner' --address '10.0.0.2' --datadir '/var/lib/mysql/'   --parent '1' '
+2017-06-14 10:02:00 1 [Note] WSREP: Shifting JOINER -> JOINED (TO: 10)
+2017-06-14 10:40:00 1 [Note] WSREP: Shifting JOINED -> OPEN (TO: 10)
+2017-06-14 10:40:01 1 [Note] WSREP: Shifting OPEN -> PRIMARY (TO: 30)
+2017-06-14 10:40:02 1 [Note] WSREP: Shifting PRIMARY -> JOINER (TO: 30)
+2017-06-14 10:40:03 1 [Note] WSREP: IST received: f3d1aa70-31a3-11e7-908c-f7a5ad9e63b1:30
+2017-06-14 10:40:04 1 [Note] WSREP: Shifting JOINER -> JOINED (TO: 30)
+`
+	nodes := []Node{{Address: "10.0.0.1"}, {Address: "10.0.0.2"}}
+	states := trackLogs(t, donor, joiner)
+
+	want := []Transfer{
+		{"SST", 0, 1, "", Period{at("09:59:59"), at("10:02:00"), false}, true},
+		{"SST", 0, -1, "10.0.0.9", Period{at("10:30:00"), at("10:31:00"), false}, true},
+		{"IST", 0, 1, "", Period{at("10:40:02"), at("10:40:04"), false}, true},
+	}
+	if got := findTransfers(states, nodes); !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
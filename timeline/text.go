@@ -0,0 +1,232 @@
+package timeline
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ansiDanger  = "\x1b[1;31m"
+	ansiSuccess = "\x1b[1;32m"
+	ansiReset   = "\x1b[0m"
+	ansiDim     = "\x1b[2m"
+
+	ansiMatcher = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+	// Used when no width is given
+	defaultTextWidth = 160
+
+	// Node columns narrower than this are unreadable, let the lines wrap instead
+	minColumnWidth = 20
+
+	columnSeparator = " | "
+)
+
+func printANSIDanger(line string) string {
+	return ansiDanger + line + ansiReset
+}
+
+func printANSISuccess(line string) string {
+	return ansiSuccess + line + ansiReset
+}
+
+// TextRenderer writes the timeline as a grid like HTMLRenderer, with a row
+// per timestamp and a column per node, for reading in a terminal
+//   - Width of the grid, 160 characters if not set
+//   - Whether to highlight danger and success with ANSI colours
+type TextRenderer struct {
+	Width int
+	Color bool
+}
+
+func (r TextRenderer) Render(w io.Writer, events Stream, nodes []Node) error {
+	if len(nodes) == 0 {
+		return errors.New("no nodes to give columns to")
+	}
+
+	width, color := r.Width, r.Color
+	if width <= 0 {
+		width = defaultTextWidth
+	}
+
+	formatter := NewPlainFormatter(nodes)
+	if color {
+		formatter = NewMessageFormatter(printANSIDanger, printANSISuccess, nodes)
+	}
+
+	timeWidth := len(timeFormatRow)
+	columnWidth := (width - timeWidth - len(columnSeparator)*len(nodes)) / len(nodes)
+	if columnWidth < minColumnWidth {
+		columnWidth = minColumnWidth
+	}
+
+	var header [][]string
+	for _, n := range nodes {
+		header = append(header, wrapText(n.Label(), columnWidth))
+	}
+	if err := writeTextRow(w, "Timestamp", header, timeWidth, columnWidth); err != nil {
+		return err
+	}
+
+	divider := strings.Repeat("-", timeWidth)
+	for range nodes {
+		divider += strings.Repeat("-", len(columnSeparator)+columnWidth)
+	}
+	if _, err := fmt.Fprintln(w, divider); err != nil {
+		return err
+	}
+
+	rows := newRowStream(events, len(nodes))
+	for rows.Next() {
+		var cells [][]string
+		for _, column := range rows.Columns {
+			var cell []string
+			for _, event := range column {
+				message := strings.Replace(formatter.Message(event), "\t", "  ", -1)
+				cell = append(cell, wrapText(message, columnWidth)...)
+				cell = append(cell, contextLines(event, columnWidth, color)...)
+			}
+			cells = append(cells, cell)
+		}
+		if err := writeTextRow(w, rows.Time, cells, timeWidth, columnWidth); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// writeTextRow writes one row of the grid, as many lines high as its tallest cell
+func writeTextRow(w io.Writer, timeString string, cells [][]string, timeWidth int, columnWidth int) error {
+	height := 1
+	for _, cell := range cells {
+		if len(cell) > height {
+			height = len(cell)
+		}
+	}
+
+	for i := 0; i < height; i++ {
+		line := padText("", timeWidth)
+		if i == 0 {
+			line = padText(timeString, timeWidth)
+		}
+		for _, cell := range cells {
+			text := ""
+			if i < len(cell) {
+				text = cell[i]
+			}
+			line += columnSeparator + padText(text, columnWidth)
+		}
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line, " ")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// visibleLen is the number of characters in s, not counting ANSI escape codes
+func visibleLen(s string) int {
+	return utf8.RuneCountInString(ansiMatcher.ReplaceAllString(s, ""))
+}
+
+func padText(s string, width int) string {
+	if padding := width - visibleLen(s); padding > 0 {
+		return s + strings.Repeat(" ", padding)
+	}
+	return s
+}
+
+// wrapText breaks s in to lines no wider than width, at spaces where
+// possible. Colours are closed at the end of each line and reopened at the
+// start of the next, so they don't bleed in to the neighbouring columns.
+func wrapText(s string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(s, "\n") {
+		line := ""
+		for i, word := range strings.Split(paragraph, " ") {
+			if i > 0 && visibleLen(line)+1+visibleLen(word) <= width {
+				line += " " + word
+				continue
+			}
+			if i > 0 {
+				lines = append(lines, line)
+			}
+			for visibleLen(word) > width {
+				head, tail := splitVisible(word, width)
+				lines = append(lines, head)
+				word = tail
+			}
+			line = word
+		}
+		lines = append(lines, line)
+	}
+
+	active := ""
+	for i, line := range lines {
+		line = active + line
+		for _, code := range ansiMatcher.FindAllString(line, -1) {
+			active = code
+			if code == ansiReset {
+				active = ""
+			}
+		}
+		if active != "" {
+			line += ansiReset
+		}
+		lines[i] = line
+	}
+
+	return lines
+}
+
+// splitVisible splits s after width visible characters
+func splitVisible(s string, width int) (string, string) {
+	count := 0
+	for i := 0; i < len(s); {
+		if loc := ansiMatcher.FindStringIndex(s[i:]); loc != nil && loc[0] == 0 {
+			i += loc[1]
+			continue
+		}
+		if count == width {
+			return s[:i], s[i:]
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		count++
+	}
+	return s, ""
+}
+
+// contextLines shows the log lines of an event with the lines around it, when
+// they were kept with --context. The event's own lines are marked with ">".
+func contextLines(event *Event, width int, color bool) []string {
+	if len(event.Before) == 0 && len(event.After) == 0 {
+		return nil
+	}
+
+	var lines []string
+	add := func(prefix string, line string, dim bool) {
+		line = prefix + strings.Replace(line, "\t", "  ", -1)
+		if dim && color {
+			line = ansiDim + line + ansiReset
+		}
+		lines = append(lines, wrapText(line, width)...)
+	}
+
+	for _, line := range event.Before {
+		add("  ", line, true)
+	}
+	for _, line := range strings.Split(event.Raw, "\n") {
+		add("> ", line, false)
+	}
+	for _, line := range event.After {
+		add("  ", line, true)
+	}
+
+	return lines
+}
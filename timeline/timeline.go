@@ -0,0 +1,51 @@
+// Package timeline finds the interesting events in the logs of the MySQL
+// nodes of a Galera cluster and puts them in to one timeline, so what each
+// node was doing at the same moment can be compared.
+//
+//	parser := timeline.NewParser()
+//	events, warnings, err := parser.ParseNode(0, &node)
+//	...
+//	timeline.HTMLRenderer{}.Render(os.Stdout, events.Stream(), nodes)
+package timeline
+
+import (
+	"io"
+	"sort"
+)
+
+// Timeline is a list of events, in the order they happened once sorted
+type Timeline []*Event
+
+// Renderer writes a stream of events in an output format, as they are read
+type Renderer interface {
+	Render(w io.Writer, events Stream, nodes []Node) error
+}
+
+// Sort puts the events in the order they happened. Events at the same time
+// keep the order they were logged in.
+func (t Timeline) Sort() {
+	sort.Slice(t, func(i, j int) bool {
+		return t[i].precedes(t[j])
+	})
+}
+
+// Merge combines sorted timelines, e.g. of each node, in to one sorted timeline
+func (t Timeline) Merge(others ...Timeline) Timeline {
+	streams := []Stream{t.Stream()}
+	for _, other := range others {
+		streams = append(streams, other.Stream())
+	}
+	merged, _ := ReadTimeline(MergeStreams(streams...))
+	return merged
+}
+
+// Filter returns the events that keep returns true for
+func (t Timeline) Filter(keep func(*Event) bool) Timeline {
+	var filtered Timeline
+	for _, event := range t {
+		if keep(event) {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,115 @@
+package timeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Galera prints one member per line, there are rarely more than a handful
+	maxViewLines = 256
+
+	viewSectionMatcher = regexp.MustCompile(`^\} (joined|left|partitioned) \{`)
+	// e.g. "1c21c3b4,0", or "0e5b7dee-8b4d,0" from Galera 4. Members are kept by
+	// the first 8 characters, as the nodes' UUIDs are.
+	viewMemberMatcher = regexp.MustCompile(`^\s*([0-9a-f]{8})(?:-[0-9a-f]{4})?,`)
+)
+
+// clusterView is the membership of the cluster after a view change
+//   - PRIM, NON_PRIM or empty
+//   - Short UUIDs of the members, and of those that joined, left or were partitioned
+type clusterView struct {
+	Status      string
+	Members     []string
+	Joined      []string
+	Left        []string
+	Partitioned []string
+}
+
+// parseMembers reads the member lists that follow the view(view_id(...) line
+func (v *clusterView) parseMembers(lines []string) {
+	section := &v.Members
+	for _, line := range lines {
+		if matches := viewSectionMatcher.FindStringSubmatch(line); matches != nil {
+			switch matches[1] {
+			case "joined":
+				section = &v.Joined
+			case "left":
+				section = &v.Left
+			case "partitioned":
+				section = &v.Partitioned
+			}
+		} else if matches := viewMemberMatcher.FindStringSubmatch(line); matches != nil {
+			*section = append(*section, matches[1])
+		}
+	}
+}
+
+// severity is danger if the cluster lost its primary component or a member
+func (v *clusterView) severity() Severity {
+	if v.Status == "NON_PRIM" || len(v.Partitioned) > 0 {
+		return SeverityDanger
+	}
+	if v.Status == "PRIM" {
+		return SeveritySuccess
+	}
+	return SeverityInfo
+}
+
+func (v *clusterView) fields() Fields {
+	// Empty lists rather than nil, so JSON output has [] not null
+	return Fields{
+		"status":      v.Status,
+		"members":     append([]string{}, v.Members...),
+		"joined":      append([]string{}, v.Joined...),
+		"left":        append([]string{}, v.Left...),
+		"partitioned": append([]string{}, v.Partitioned...),
+	}
+}
+
+// formatView summarises a view, e.g. "Cluster view: PRIM (3 members; left: mysql-2)",
+// naming members from their UUIDs where known
+func formatView(e *Event, f *MessageFormatter) string {
+	status := e.Fields["status"].(string)
+	if status == "empty" {
+		return fmt.Sprintf("Cluster view: %s", status)
+	}
+
+	members := e.Fields["members"].([]string)
+	joined := e.Fields["joined"].([]string)
+	left := e.Fields["left"].([]string)
+	partitioned := e.Fields["partitioned"].([]string)
+
+	count := fmt.Sprintf("%d members", len(members))
+	if len(members) == 1 {
+		count = "1 member"
+	}
+
+	details := []string{count}
+	if len(joined) > 0 {
+		details = append(details, fmt.Sprintf("joined: %s", nameUUIDs(joined, f)))
+	}
+	if len(left) > 0 {
+		details = append(details, fmt.Sprintf("left: %s", nameUUIDs(left, f)))
+	}
+	if len(partitioned) > 0 {
+		details = append(details, f.Danger(fmt.Sprintf("partitioned: %s", nameUUIDs(partitioned, f))))
+	}
+
+	if status == "NON_PRIM" {
+		status = f.Danger(status)
+	} else if status == "PRIM" {
+		status = f.Success(status)
+	}
+
+	return fmt.Sprintf("Cluster view: %s (%s)", status, strings.Join(details, "; "))
+}
+
+func nameUUIDs(uuids []string, f *MessageFormatter) string {
+	var named []string
+	for _, uuid := range uuids {
+		named = append(named, f.NodeByUUID(uuid))
+	}
+	return strings.Join(named, ", ")
+}
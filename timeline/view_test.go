@@ -0,0 +1,80 @@
+package timeline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClusterViewParseMembers(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    string
+		want     clusterView
+		severity Severity
+	}{
+		{
+			"Galera 3",
+			`	1c21c3b4,0
+	8a7f3cd1,0
+} joined {
+	8a7f3cd1,0
+} left {
+} partitioned {
+})`,
+			clusterView{"PRIM", []string{"1c21c3b4", "8a7f3cd1"}, []string{"8a7f3cd1"}, nil, nil},
+			SeveritySuccess,
+		},
+		{
+			"Galera 4",
+			`	0e5b7dee-8b4d,0
+	5a1c2b3d-9f10,0
+} joined {
+} left {
+	6b6b6b6b-1111,0
+} partitioned {
+	7c7c7c7c-aaaa,0
+})`,
+			clusterView{"PRIM", []string{"0e5b7dee", "5a1c2b3d"}, nil, []string{"6b6b6b6b"}, []string{"7c7c7c7c"}},
+			SeverityDanger,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			view := clusterView{Status: "PRIM"}
+			view.parseMembers(strings.Split(test.lines, "\n"))
+			if !reflect.DeepEqual(view, test.want) {
+				t.Errorf("got  %+v\nwant %+v", view, test.want)
+			}
+			if got := view.severity(); got != test.severity {
+				t.Errorf("severity: got %s, want %s", got, test.severity)
+			}
+		})
+	}
+}
+
+func TestFormatViewNamesMembers(t *testing.T) {
+	log := `2021-03-01T10:00:01.000000Z 0 [Note] [MY-000000] [Galera] view(view_id(PRIM,0e5b7dee-8b4d,3) memb {
+	0e5b7dee-8b4d,0
+	5a1c2b3d-9f10,0
+} joined {
+} left {
+} partitioned {
+	7c7c7c7c-aaaa,0
+})
+`
+	events, warnings := NewParser().ParseReader(0, strings.NewReader(log))
+	if len(warnings) > 0 || len(events) != 1 {
+		t.Fatalf("got %d events and warnings %v, want 1 event", len(events), warnings)
+	}
+
+	nodes := []Node{{Name: "mysql-2", UUIDs: []string{"7c7c7c7c"}}}
+	want := "Cluster view: PRIM (2 members; partitioned: mysql-2)"
+	if got := NewPlainFormatter(nodes).Message(events[0]); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if events[0].Severity != SeverityDanger {
+		t.Errorf("severity: got %s, want danger", events[0].Severity)
+	}
+}
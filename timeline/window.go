@@ -0,0 +1,198 @@
+package timeline
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window is the part of the timeline to keep. A zero Since or Until leaves
+// that end open.
+type Window struct {
+	Since time.Time
+	Until time.Time
+}
+
+// Contains is true if the time is in the window, including its ends
+func (w Window) Contains(t time.Time) bool {
+	if !w.Since.IsZero() && t.Before(w.Since) {
+		return false
+	}
+	if !w.Until.IsZero() && t.After(w.Until) {
+		return false
+	}
+	return true
+}
+
+// excludesLog is true if none of the events in logs[i] of a node's sorted logs
+// can be in the window, because it starts after the window ends or the next
+// log starts before the window does
+func (w Window) excludesLog(logs []LogSource, i int) bool {
+	if !w.Until.IsZero() {
+		if first, ok := logs[i].firstTime(); ok && first.After(w.Until) {
+			return true
+		}
+	}
+	if !w.Since.IsZero() && i+1 < len(logs) {
+		if next, ok := logs[i+1].firstTime(); ok && next.Before(w.Since) {
+			return true
+		}
+	}
+	return false
+}
+
+// TimeSpec is one end of a window as it was written, either an absolute time
+// or relative to the first or last event matching some text
+//   - Absolute time, zero if it is relative
+//   - Text the event's type or message contains, ignoring case
+//   - Whether the last matching event is meant, rather than the first
+//   - How long after the event, negative for before
+//   - Whether the offset goes both ways, for a window around the event
+type TimeSpec struct {
+	Time   time.Time
+	Event  string
+	Last   bool
+	Offset time.Duration
+	Around bool
+}
+
+// Formats of absolute times, taken to be UTC like the logs unless a zone is given
+var timeSpecFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+var (
+	// e.g. "2h before last BOOTSTRAPPING" or "first NON_PRIM"
+	relativeTimeMatcher = regexp.MustCompile(`^(?:(\S+) (before|after) )?(first|last) (.+)$`)
+	// e.g. "around first NON_PRIM ±30m"
+	aroundTimeMatcher = regexp.MustCompile(`^around (first|last) (.+) (?:±|\+-|\+/-) ?(\S+)$`)
+)
+
+// ParseTimeSpec reads one end of a window: an absolute time such as
+// "2017-06-14 19:10:00", "2h before last BOOTSTRAPPING", "30m after first
+// NON_PRIM", "last Bootstrap" or "around first NON_PRIM ±30m"
+func ParseTimeSpec(s string) (TimeSpec, error) {
+	s = strings.TrimSpace(s)
+
+	for _, format := range timeSpecFormats {
+		if t, err := time.Parse(format, s); err == nil {
+			return TimeSpec{Time: t}, nil
+		}
+	}
+
+	if match := aroundTimeMatcher.FindStringSubmatch(s); match != nil {
+		offset, err := parseOffset(match[3])
+		if err != nil {
+			return TimeSpec{}, err
+		}
+		return TimeSpec{Event: match[2], Last: match[1] == "last", Offset: offset, Around: true}, nil
+	}
+
+	if match := relativeTimeMatcher.FindStringSubmatch(s); match != nil {
+		spec := TimeSpec{Event: match[4], Last: match[3] == "last"}
+		if match[1] != "" {
+			offset, err := parseOffset(match[1])
+			if err != nil {
+				return TimeSpec{}, err
+			}
+			spec.Offset = offset
+			if match[2] == "before" {
+				spec.Offset = -offset
+			}
+		}
+		return spec, nil
+	}
+
+	return TimeSpec{}, fmt.Errorf("%q is not a time such as \"2017-06-14 19:10:00\", \"2h before last BOOTSTRAPPING\" or \"around first NON_PRIM ±30m\"", s)
+}
+
+// parseOffset reads a duration such as "30m" or "1h30m", also allowing days
+// such as "2d"
+func parseOffset(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64)
+		if err == nil && days >= 0 {
+			return time.Duration(days * float64(24*time.Hour)), nil
+		}
+	}
+	offset, err := time.ParseDuration(s)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("%q is not a duration such as 30m, 2h or 1d", s)
+	}
+	return offset, nil
+}
+
+// IsZero is true if the end was not given, leaving it open
+func (s TimeSpec) IsZero() bool {
+	return s.Time.IsZero() && s.Event == ""
+}
+
+// Relative is true if the time depends on an event in the timeline
+func (s TimeSpec) Relative() bool {
+	return s.Event != ""
+}
+
+func (s TimeSpec) matches(event *Event, formatter *MessageFormatter) bool {
+	text := strings.ToLower(s.Event)
+	return strings.Contains(strings.ToLower(event.Type), text) ||
+		strings.Contains(strings.ToLower(formatter.Message(event)), text)
+}
+
+// ResolveWindow works out the window between since and until, either of which
+// may be zero to leave that end open. If either is relative the events are
+// read to the end to find the event it refers to, otherwise events may be nil.
+// A window around an event given for one end also sets the other end, unless
+// it is given too.
+func ResolveWindow(since, until TimeSpec, events Stream, nodes []Node) (Window, error) {
+	specs := []TimeSpec{since, until}
+	found := make([]*Event, len(specs))
+
+	if since.Relative() || until.Relative() {
+		formatter := NewPlainFormatter(nodes)
+		for events.Next() {
+			event := events.Event()
+			for i, spec := range specs {
+				if spec.Relative() && (found[i] == nil || spec.Last) && spec.matches(event, formatter) {
+					found[i] = event
+				}
+			}
+		}
+		if err := events.Err(); err != nil {
+			return Window{}, err
+		}
+	}
+
+	times := make([]time.Time, len(specs))
+	for i, spec := range specs {
+		if !spec.Relative() {
+			times[i] = spec.Time
+			continue
+		}
+		if found[i] == nil {
+			return Window{}, fmt.Errorf("no event matches %q", spec.Event)
+		}
+		times[i] = found[i].Datetime.Add(spec.Offset)
+		if spec.Around && i == 0 {
+			times[i] = found[i].Datetime.Add(-spec.Offset)
+		}
+	}
+
+	window := Window{times[0], times[1]}
+	if since.Around && until.IsZero() {
+		window.Until = found[0].Datetime.Add(since.Offset)
+	}
+	if until.Around && since.IsZero() {
+		window.Since = found[1].Datetime.Add(-until.Offset)
+	}
+
+	if !window.Since.IsZero() && !window.Until.IsZero() && window.Until.Before(window.Since) {
+		return Window{}, fmt.Errorf("the window starts at %s, after it ends at %s", window.Since.Format(timeFormatDefault), window.Until.Format(timeFormatDefault))
+	}
+	return window, nil
+}
@@ -0,0 +1,160 @@
+package timeline
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseTimeSpec(t *testing.T) {
+	at := func(s string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		spec string
+		want TimeSpec
+	}{
+		{"2017-06-14 19:10:00", TimeSpec{Time: at("2017-06-14T19:10:00Z")}},
+		{"2017-06-14 19:10", TimeSpec{Time: at("2017-06-14T19:10:00Z")}},
+		{"2017-06-14T19:10:00", TimeSpec{Time: at("2017-06-14T19:10:00Z")}},
+		{"2017-06-14T20:10:00+01:00", TimeSpec{Time: at("2017-06-14T19:10:00Z")}},
+		{" 2017-06-14 ", TimeSpec{Time: at("2017-06-14T00:00:00Z")}},
+		{"first NON_PRIM", TimeSpec{Event: "NON_PRIM"}},
+		{"last Bootstrap", TimeSpec{Event: "Bootstrap", Last: true}},
+		{"2h before last BOOTSTRAPPING", TimeSpec{Event: "BOOTSTRAPPING", Last: true, Offset: -2 * time.Hour}},
+		{"30m after first node consistency", TimeSpec{Event: "node consistency", Offset: 30 * time.Minute}},
+		{"1d after first NON_PRIM", TimeSpec{Event: "NON_PRIM", Offset: 24 * time.Hour}},
+		{"around first NON_PRIM ±30m", TimeSpec{Event: "NON_PRIM", Offset: 30 * time.Minute, Around: true}},
+		{"around last Bootstrap +-1h30m", TimeSpec{Event: "Bootstrap", Last: true, Offset: 90 * time.Minute, Around: true}},
+		{"around first NON_PRIM +/- 10s", TimeSpec{Event: "NON_PRIM", Offset: 10 * time.Second, Around: true}},
+	}
+
+	for _, test := range tests {
+		got, err := ParseTimeSpec(test.spec)
+		if err != nil {
+			t.Errorf("%q: %s", test.spec, err)
+			continue
+		}
+		if !got.Time.Equal(test.want.Time) || got.Event != test.want.Event || got.Last != test.want.Last ||
+			got.Offset != test.want.Offset || got.Around != test.want.Around {
+			t.Errorf("%q: got %+v, want %+v", test.spec, got, test.want)
+		}
+	}
+
+	for _, spec := range []string{"", "yesterday", "2017-06-14 25:00", "2x before first NON_PRIM", "-2h after first NON_PRIM", "around first NON_PRIM"} {
+		if _, err := ParseTimeSpec(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestResolveWindow(t *testing.T) {
+	start := time.Date(2017, 6, 14, 19, 0, 0, 0, time.UTC)
+	event := func(minutes int, eventType string, raw string) *Event {
+		e := NewEvent(start.Add(time.Duration(minutes)*time.Minute), 0, SeverityInfo, Fields{}, []string{raw})
+		e.Type = eventType
+		return e
+	}
+	events := Timeline{
+		event(0, "Cluster View", "WSREP: view(view_id(NON_PRIM,55433460,408) memb {"),
+		event(10, "Bootstrap", "WSREP: 'wsrep-new-cluster' option used, bootstrapping the cluster"),
+		event(20, "Cluster View", "WSREP: view(view_id(NON_PRIM,55433460,409) memb {"),
+	}
+	minutes := func(m int) time.Time {
+		return start.Add(time.Duration(m) * time.Minute)
+	}
+
+	tests := []struct {
+		since string
+		until string
+		want  Window
+	}{
+		{"", "", Window{}},
+		{"2017-06-14 19:05", "", Window{Since: minutes(5)}},
+		{"first NON_PRIM", "", Window{Since: minutes(0)}},
+		{"last NON_PRIM", "", Window{Since: minutes(20)}},
+		{"5m before first bootstrap", "30m after last non_prim", Window{minutes(5), minutes(50)}},
+		{"around first Bootstrap ±5m", "", Window{minutes(5), minutes(15)}},
+		{"", "around first Bootstrap ±5m", Window{minutes(5), minutes(15)}},
+		{"2017-06-14 19:00", "around first Bootstrap ±5m", Window{minutes(0), minutes(15)}},
+	}
+
+	for _, test := range tests {
+		var since, until TimeSpec
+		var err error
+		if test.since != "" {
+			if since, err = ParseTimeSpec(test.since); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if test.until != "" {
+			if until, err = ParseTimeSpec(test.until); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got, err := ResolveWindow(since, until, events.Stream(), nil)
+		if err != nil {
+			t.Errorf("%q to %q: %s", test.since, test.until, err)
+			continue
+		}
+		if !got.Since.Equal(test.want.Since) || !got.Until.Equal(test.want.Until) {
+			t.Errorf("%q to %q: got %v, want %v", test.since, test.until, got, test.want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"first SYNCED", ""}, {"last Bootstrap", "first NON_PRIM"}} {
+		since, _ := ParseTimeSpec(bad[0])
+		until, _ := ParseTimeSpec(bad[1])
+		if _, err := ResolveWindow(since, until, events.Stream(), nil); err == nil {
+			t.Errorf("%q to %q: expected an error", bad[0], bad[1])
+		}
+	}
+}
+
+func TestWindowExcludesLog(t *testing.T) {
+	dir := t.TempDir()
+	log := func(name string, text string) LogSource {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return LogSource{Path: path}
+	}
+
+	// Sorted oldest first, as the logs of a node are read
+	logs := []LogSource{
+		log("mysql.err.log.3", "2017-06-14 10:00:00 1 [Note] WSREP: Shifting\n"),
+		log("mysql.err.log.2", "2017-06-14 11:00:00 1 [Note] WSREP: Shifting\n"),
+		log("mysql.err.log.1", ""),
+		log("mysql.err.log", "2017-06-14 12:00:00 1 [Note] WSREP: Shifting\n"),
+	}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2017, 6, 14, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		window Window
+		want   []bool
+	}{
+		{Window{}, []bool{false, false, false, false}},
+		{Window{Since: at(11, 30)}, []bool{true, false, false, false}},
+		{Window{Since: at(12, 30)}, []bool{true, false, true, false}},
+		{Window{Until: at(10, 30)}, []bool{false, true, false, true}},
+		{Window{Since: at(10, 15), Until: at(10, 45)}, []bool{false, true, false, true}},
+	}
+
+	for _, test := range tests {
+		for i, want := range test.want {
+			if got := test.window.excludesLog(logs, i); got != want {
+				t.Errorf("%v: log %d: got %t, want %t", test.window, i, got, want)
+			}
+		}
+	}
+}